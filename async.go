@@ -0,0 +1,122 @@
+package safe
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// asyncHandler passes panics to a handler from a dedicated goroutine.
+type asyncHandler struct {
+	fn    func(err error)
+	queue chan error
+
+	mu      sync.Mutex
+	pending int           // number of queued panics not yet handled
+	idle    chan struct{} // closed when pending drops to zero
+	closed  bool          // whether queue is closed
+}
+
+var (
+	asyncPanics  atomic.Pointer[asyncHandler] // current async panic handler
+	droppedCount atomic.Uint64                // number of dropped panics
+)
+
+// SetAsyncPanicHandler configures a global panic handler like SetPanicHandler,
+// except that fn is called from a dedicated goroutine so that slow reporting
+// never blocks the goroutine that panicked. Up to queueSize panics are queued
+// for fn; if the queue is full, further panics are dropped and counted by
+// DroppedPanics.
+//
+// Use FlushPanics to wait for queued panics to be handled, e.g. on shutdown.
+// Once another global panic handler replaces fn, panics already queued are
+// still passed to fn, after which its goroutine exits. Reinstalling the
+// replaced handler, e.g. one saved with GetPanicHandler, does not restart it:
+// panics passed to it are then written to the log and counted by
+// DroppedPanics. Call SetAsyncPanicHandler again instead.
+func SetAsyncPanicHandler(fn func(err error), queueSize int) {
+	a := &asyncHandler{
+		fn:    fn,
+		queue: make(chan error, queueSize),
+	}
+	go a.run()
+	storeHandlers([]globalHandler{{fn: a.enqueue}}, a)
+}
+
+// DroppedPanics returns the number of panics dropped because the queue of the
+// asynchronous panic handler was full or the handler had been replaced.
+func DroppedPanics() uint64 {
+	return droppedCount.Load()
+}
+
+// FlushPanics blocks until all panics queued for the asynchronous panic handler
+// have been handled, or until ctx is done, in which case it returns ctx.Err().
+// It returns immediately if no asynchronous panic handler is set.
+func FlushPanics(ctx context.Context) error {
+	a := asyncPanics.Load()
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	if a.pending == 0 {
+		a.mu.Unlock()
+		return nil
+	}
+	if a.idle == nil {
+		a.idle = make(chan struct{})
+	}
+	idle := a.idle
+	a.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue queues err for the handler, dropping it if the queue is full. If the
+// handler has been closed, err is dropped and written to the log instead.
+func (a *asyncHandler) enqueue(err error) {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		droppedCount.Add(1)
+		callPanicHandler(nil, err)
+		return
+	}
+	defer a.mu.Unlock()
+	select {
+	case a.queue <- err:
+		a.pending++
+	default:
+		droppedCount.Add(1)
+	}
+}
+
+// close stops accepting panics, letting run exit once the queue is drained.
+func (a *asyncHandler) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+}
+
+// run passes queued panics to the handler.
+func (a *asyncHandler) run() {
+	for err := range a.queue {
+		callPanicHandler(a.fn, err)
+
+		a.mu.Lock()
+		a.pending--
+		if a.pending == 0 && a.idle != nil {
+			close(a.idle)
+			a.idle = nil
+		}
+		a.mu.Unlock()
+	}
+}
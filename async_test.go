@@ -0,0 +1,104 @@
+package safe_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+// panicAndHandle reports a panic to the global panic handler from the calling
+// goroutine.
+func panicAndHandle(val interface{}) {
+	defer func() { _ = safe.HandlePanic(recover()) }()
+	panic(val)
+}
+
+func TestSetAsyncPanicHandler(t *testing.T) {
+	release := make(chan struct{})
+	errs := make(chan error, 1)
+	safe.SetAsyncPanicHandler(func(err error) {
+		<-release
+		errs <- err
+	}, 1)
+	t.Cleanup(safe.ResetPanicHandler)
+
+	done := make(chan struct{})
+	go func() {
+		panicAndHandle("boom")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("panicking goroutine blocked on the slow handler")
+	}
+
+	close(release)
+	if err := safe.FlushPanics(context.Background()); err != nil {
+		t.Fatalf("FlushPanics() = %v", err)
+	}
+	requirePanicError(t, <-errs, "boom")
+}
+
+func TestSetAsyncPanicHandlerReplaced(t *testing.T) {
+	release := make(chan struct{})
+	errs := make(chan error, 1)
+	safe.SetAsyncPanicHandler(func(err error) {
+		<-release
+		errs <- err
+	}, 1)
+	t.Cleanup(safe.ResetPanicHandler)
+	panicAndHandle("boom")
+
+	// The replaced handler must no longer be flushed.
+	safe.SetPanicHandler(func(error) {})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := safe.FlushPanics(ctx); err != nil {
+		t.Errorf("FlushPanics() after replacing the handler = %v, want nil", err)
+	}
+
+	// Panics already queued are still handled by the replaced handler.
+	close(release)
+	select {
+	case err := <-errs:
+		requirePanicError(t, err, "boom")
+	case <-time.After(time.Second):
+		t.Fatal("queued panic was not handled")
+	}
+}
+
+func TestSetAsyncPanicHandlerRestored(t *testing.T) {
+	logs := make(chan string, 1)
+	safe.SetFallbackLogger(func(format string, args ...interface{}) {
+		logs <- fmt.Sprintf(format, args...)
+	})
+	t.Cleanup(func() { safe.SetFallbackLogger(nil) })
+	errs := make(chan error, 1)
+	safe.SetAsyncPanicHandler(func(err error) { errs <- err }, 1)
+	t.Cleanup(safe.ResetPanicHandler)
+
+	// Restoring a replaced async handler does not restart it, but panics are
+	// still logged and counted rather than lost.
+	old := safe.GetPanicHandler()
+	safe.SetPanicHandler(func(error) {})
+	safe.SetPanicHandler(old)
+	dropped := safe.DroppedPanics()
+	panicAndHandle("boom")
+
+	if n := safe.DroppedPanics() - dropped; n != 1 {
+		t.Errorf("DroppedPanics() increased by %d, want 1", n)
+	}
+	if msg := <-logs; !strings.HasPrefix(msg, "panic: boom") {
+		t.Errorf("got log %q, want the panic", msg)
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("replaced handler got %v", err)
+	default:
+	}
+}
@@ -0,0 +1,95 @@
+package safe_test
+
+import (
+	"errors"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+	"golang.org/x/sync/errgroup"
+)
+
+var errBench = errors.New("bench")
+
+func noPanic() error { return nil }
+
+func noPanicResult() (interface{}, error) { return nil, errBench }
+
+func TestDoNoPanicAllocs(t *testing.T) {
+	if n := testing.AllocsPerRun(100, func() { _ = safe.Do(noPanic) }); n != 0 {
+		t.Errorf("Do allocated %v times per call, want 0", n)
+	}
+	if n := testing.AllocsPerRun(100, func() { _, _ = safe.DoWithResult(noPanicResult) }); n != 0 {
+		t.Errorf("DoWithResult allocated %v times per call, want 0", n)
+	}
+}
+
+// TestGroupGoAllocs checks that Group.Go adds at most the task closure to the
+// allocations of errgroup.Group.Go.
+func TestGroupGoAllocs(t *testing.T) {
+	var eg errgroup.Group
+	base := testing.AllocsPerRun(100, func() { eg.Go(noPanic) })
+	_ = eg.Wait()
+	var g safe.Group
+	n := testing.AllocsPerRun(100, func() { g.Go(noPanic) })
+	_ = g.Wait()
+	if n > base+1 {
+		t.Errorf("Group.Go allocated %v times per call, want at most %v", n, base+1)
+	}
+}
+
+func BenchmarkDoNoPanic(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = safe.Do(noPanic)
+	}
+}
+
+func BenchmarkDoWithResultNoPanic(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = safe.DoWithResult(noPanicResult)
+	}
+}
+
+func BenchmarkGroupGoNoPanic(b *testing.B) {
+	b.ReportAllocs()
+	var g safe.Group
+	for i := 0; i < b.N; i++ {
+		g.Go(noPanic)
+	}
+	_ = g.Wait()
+}
+
+func panicking() error { panic("bench") }
+
+func BenchmarkPanicErrorStack(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = safe.Do(panicking)
+	}
+}
+
+func BenchmarkPanicErrorNoStack(b *testing.B) {
+	safe.SetCaptureStack(false)
+	defer safe.SetCaptureStack(true)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = safe.Do(panicking)
+	}
+}
+
+// BenchmarkDoQuietPanic is the DoQuiet counterpart of BenchmarkPanicErrorStack.
+func BenchmarkDoQuietPanic(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = safe.DoQuiet(panicking)
+	}
+}
+
+func TestDoQuietAllocs(t *testing.T) {
+	do := testing.AllocsPerRun(100, func() { _ = safe.Do(panicking) })
+	quiet := testing.AllocsPerRun(100, func() { _ = safe.DoQuiet(panicking) })
+	if quiet >= do {
+		t.Errorf("DoQuiet allocated %v times per call, want fewer than Do's %v", quiet, do)
+	}
+}
@@ -0,0 +1,66 @@
+package safe
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// panicBudget tracks recent panics against the limit set by SetPanicBudget.
+var panicBudget struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	onExceed func()
+	times    []time.Time // times of recent panics, oldest first
+}
+
+// SetPanicBudget configures a safety valve for systemic failures: if more than
+// max panics are recovered by this package within any period of the given
+// window, onExceed is called. If onExceed is nil, the program instead logs the
+// failure and exits with status 1. onExceed is called again for each further
+// panic while the budget remains exceeded.
+//
+// A max of zero or less removes the budget, which is the default.
+func SetPanicBudget(max int, window time.Duration, onExceed func()) {
+	b := &panicBudget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.max = max
+	b.window = window
+	b.onExceed = onExceed
+	b.times = nil
+}
+
+// spendPanicBudget records a recovered panic against the panic budget.
+func spendPanicBudget() {
+	b := &panicBudget
+	b.mu.Lock()
+	if b.max <= 0 {
+		b.mu.Unlock()
+		return
+	}
+
+	now := getClock().Now()
+	cutoff := now.Add(-b.window)
+	i := 0
+	for i < len(b.times) && !b.times[i].After(cutoff) {
+		i++
+	}
+	b.times = append(b.times[i:], now)
+	if len(b.times) > b.max+1 {
+		b.times = b.times[1:]
+	}
+	exceeded := len(b.times) > b.max
+	max, window, onExceed := b.max, b.window, b.onExceed
+	b.mu.Unlock()
+
+	if !exceeded {
+		return
+	}
+	if onExceed == nil {
+		logf("panic budget exceeded: more than %d panics within %v\n", max, window)
+		os.Exit(1)
+	}
+	onExceed()
+}
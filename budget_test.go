@@ -0,0 +1,84 @@
+package safe_test
+
+import (
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+// useBudget sets a panic budget for the duration of the test, returning a
+// function that reports how many times it has been exceeded.
+func useBudget(t *testing.T, max int, window time.Duration) func() int {
+	exceeded := 0
+	safe.SetPanicBudget(max, window, func() { exceeded++ })
+	t.Cleanup(func() { safe.SetPanicBudget(0, 0, nil) })
+	return func() int { return exceeded }
+}
+
+// spend recovers n panics, spending them against the panic budget.
+func spend(n int) {
+	for i := 0; i < n; i++ {
+		_ = safe.Do(func() error { panic("boom") })
+	}
+}
+
+func TestSetPanicBudget(t *testing.T) {
+	useFakeClock(t)
+	exceeded := useBudget(t, 3, time.Minute)
+
+	spend(3)
+	if n := exceeded(); n != 0 {
+		t.Fatalf("exceeded %d times after 3 panics, want 0", n)
+	}
+	spend(1)
+	if n := exceeded(); n != 1 {
+		t.Fatalf("exceeded %d times after 4 panics, want 1", n)
+	}
+	spend(1)
+	if n := exceeded(); n != 2 {
+		t.Errorf("exceeded %d times after 5 panics, want 2", n)
+	}
+}
+
+func TestSetPanicBudgetWindow(t *testing.T) {
+	clock := useFakeClock(t)
+	exceeded := useBudget(t, 2, time.Minute)
+
+	spend(2)
+	// A panic exactly one window later no longer counts the first two.
+	clock.Advance(time.Minute)
+	spend(1)
+	if n := exceeded(); n != 0 {
+		t.Fatalf("exceeded %d times at the window boundary, want 0", n)
+	}
+
+	// Within the window, the third panic exceeds the budget.
+	clock.Advance(30 * time.Second)
+	spend(2)
+	if n := exceeded(); n != 1 {
+		t.Errorf("exceeded %d times within the window, want 1", n)
+	}
+}
+
+func TestSetPanicBudgetReset(t *testing.T) {
+	clock := useFakeClock(t)
+	exceeded := useBudget(t, 1, time.Minute)
+
+	spend(2)
+	if n := exceeded(); n != 1 {
+		t.Fatalf("exceeded %d times, want 1", n)
+	}
+
+	// Once the window slides past the earlier panics, the budget is available
+	// again.
+	clock.Advance(2 * time.Minute)
+	spend(1)
+	if n := exceeded(); n != 1 {
+		t.Errorf("exceeded %d times after the window slid, want 1", n)
+	}
+	spend(1)
+	if n := exceeded(); n != 2 {
+		t.Errorf("exceeded %d times after exhausting the budget again, want 2", n)
+	}
+}
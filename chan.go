@@ -0,0 +1,34 @@
+package safe
+
+import "runtime"
+
+// SafeClose closes ch, reporting false instead of panicking if it was already
+// closed. Any other panic, such as closing a nil channel, is not recovered.
+func SafeClose[T any](ch chan T) (closed bool) {
+	defer recoverClosed("close of closed channel", &closed)
+	close(ch)
+	return true
+}
+
+// SafeSend sends v on ch, blocking until it is received or buffered. It
+// reports false instead of panicking if ch is closed. Any other panic is not
+// recovered.
+func SafeSend[T any](ch chan T, v T) (sent bool) {
+	defer recoverClosed("send on closed channel", &sent)
+	ch <- v
+	return true
+}
+
+// recoverClosed recovers a runtime panic with the given message, setting ok to
+// false. Any other panic is re-panicked.
+func recoverClosed(msg string, ok *bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if err, isRuntime := r.(runtime.Error); isRuntime && err.Error() == msg {
+		*ok = false
+		return
+	}
+	panic(r)
+}
@@ -0,0 +1,38 @@
+package safe_test
+
+import (
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestSafeClose(t *testing.T) {
+	ch := make(chan int)
+	if !safe.SafeClose(ch) {
+		t.Error("SafeClose() = false for an open channel")
+	}
+	if safe.SafeClose(ch) {
+		t.Error("SafeClose() = true for a closed channel")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SafeClose recovered closing a nil channel")
+		}
+	}()
+	safe.SafeClose[int](nil)
+}
+
+func TestSafeSend(t *testing.T) {
+	ch := make(chan int, 1)
+	if !safe.SafeSend(ch, 1) {
+		t.Error("SafeSend() = false for an open channel")
+	}
+	if v := <-ch; v != 1 {
+		t.Errorf("received %d, want 1", v)
+	}
+	close(ch)
+	if safe.SafeSend(ch, 2) {
+		t.Error("SafeSend() = true for a closed channel")
+	}
+}
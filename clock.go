@@ -0,0 +1,56 @@
+package safe
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// A Clock provides the current time and timers to RetryWithBackoff,
+// DoWithTimeout, Group.WaitTimeout, Group.SetRate, Throttle and SetPanicBudget,
+// and the time recorded in each PanicError. It allows tests to control time.
+//
+// The helpers scheduling work on timers, namely AfterFunc, Tick, Periodic,
+// Debounce and GoWithTimeout, as well as SetPanicLogRateLimit, always use the
+// time package.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After waits for d to elapse and then sends the current time on the
+	// returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the default Clock, backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clockBox wraps a Clock so it can be stored in an atomic.Value.
+type clockBox struct{ c Clock }
+
+var clock atomic.Value // current Clock, as clockBox
+
+// SetClock configures the Clock used by the functions listed in the Clock
+// documentation. If c is nil, the real clock is restored. It is intended for
+// tests.
+func SetClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+	clock.Store(clockBox{c})
+}
+
+// after returns a channel that receives after d has elapsed according to the
+// configured Clock.
+func after(d time.Duration) <-chan time.Time {
+	return getClock().After(d)
+}
+
+// getClock returns the configured Clock.
+func getClock() Clock {
+	if b, ok := clock.Load().(clockBox); ok {
+		return b.c
+	}
+	return realClock{}
+}
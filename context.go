@@ -0,0 +1,56 @@
+package safe
+
+import "context"
+
+// panicHandlerKey is the context key for a panic handler.
+type panicHandlerKey struct{}
+
+// ContextWithPanicHandler returns a copy of ctx carrying fn as a panic handler
+// scoped to it. This lets request-scoped code attach a handler with request
+// metadata without changing global state.
+//
+// Panics recovered by the context-aware functions GoCtx and DoWithContext are
+// passed to the handler in their context if there is one. Otherwise, GoCtx
+// passes them to the global panic handlers, or to the log if none are set.
+func ContextWithPanicHandler(ctx context.Context, fn func(err error)) context.Context {
+	return context.WithValue(ctx, panicHandlerKey{}, fn)
+}
+
+// contextPanicHandler returns the panic handler in ctx, or nil if none.
+func contextPanicHandler(ctx context.Context) func(err error) {
+	fn, _ := ctx.Value(panicHandlerKey{}).(func(err error))
+	return fn
+}
+
+// handlePanicErrorContext is like handlePanicError, but passes err to the
+// panic handler in ctx instead of the global panic handlers, if there is one.
+func handlePanicErrorContext(ctx context.Context, err PanicError) error {
+	fn := contextPanicHandler(ctx)
+	if fn == nil {
+		return handlePanicError(err)
+	}
+	callPanicHandler(fn, err)
+	checkFatal(err.val)
+	return err
+}
+
+// recoveredKey is the context key marking contexts passed to recovered
+// functions.
+type recoveredKey struct{}
+
+// withRecovered returns a copy of ctx marked as passed to a recovered
+// function.
+func withRecovered(ctx context.Context) context.Context {
+	if IsRecoveredContext(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, recoveredKey{}, true)
+}
+
+// IsRecoveredContext reports whether ctx, or a context it is derived from, was
+// passed by GoCtx, GoFrom or DoWithContext to a function whose panics are
+// recovered by this package.
+func IsRecoveredContext(ctx context.Context) bool {
+	ok, _ := ctx.Value(recoveredKey{}).(bool)
+	return ok
+}
@@ -0,0 +1,107 @@
+package safe_test
+
+import (
+	"context"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestContextWithPanicHandler(t *testing.T) {
+	global := handlePanics(t)
+	scoped := make(chan error, 1)
+	ctx := safe.ContextWithPanicHandler(context.Background(), func(err error) { scoped <- err })
+
+	safe.GoCtx(ctx, func(context.Context) { panic("scoped") })
+	requirePanicError(t, <-scoped, "scoped")
+
+	// Without a handler in the context, panics fall back to the global one.
+	safe.GoCtx(context.Background(), func(context.Context) { panic("global") })
+	requirePanicError(t, <-global, "global")
+	select {
+	case err := <-global:
+		t.Errorf("global handler also got %v", err)
+	case err := <-scoped:
+		t.Errorf("context handler also got %v", err)
+	default:
+	}
+}
+
+func TestGoFrom(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "trace"))
+	cancel()
+
+	type seen struct {
+		value     interface{}
+		err       error
+		recovered bool
+	}
+	ch := make(chan seen, 1)
+	safe.GoFrom(ctx, func(ctx context.Context) {
+		ch <- seen{ctx.Value(key{}), ctx.Err(), safe.IsRecoveredContext(ctx)}
+	})
+	got := <-ch
+	if got.value != "trace" {
+		t.Errorf("got value %v, want the value of the parent context", got.value)
+	}
+	if got.err != nil {
+		t.Errorf("got context error %v, want the cancellation not to propagate", got.err)
+	}
+	if !got.recovered {
+		t.Error("IsRecoveredContext() = false in GoFrom")
+	}
+}
+
+func TestIsRecoveredContext(t *testing.T) {
+	if safe.IsRecoveredContext(context.Background()) {
+		t.Error("IsRecoveredContext() = true for a plain context")
+	}
+
+	var recovered bool
+	safe.DoWithContext(context.Background(), func(ctx context.Context) error {
+		// Derived contexts are recognized too.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		recovered = safe.IsRecoveredContext(ctx)
+		return nil
+	})
+	if !recovered {
+		t.Error("IsRecoveredContext() = false in DoWithContext")
+	}
+
+	ch := make(chan bool, 1)
+	safe.GoCtx(context.Background(), func(ctx context.Context) {
+		ch <- safe.IsRecoveredContext(ctx)
+	})
+	if !<-ch {
+		t.Error("IsRecoveredContext() = false in GoCtx")
+	}
+}
+
+func TestInRecoveredContext(t *testing.T) {
+	safe.TrackGoroutines(true)
+	t.Cleanup(func() { safe.TrackGoroutines(false) })
+	if safe.InRecoveredContext() {
+		t.Error("InRecoveredContext() = true outside a recovered function")
+	}
+	ch := make(chan bool, 1)
+	safe.Go(func() { ch <- safe.InRecoveredContext() })
+	if !<-ch {
+		t.Error("InRecoveredContext() = false in Go")
+	}
+
+	// As documented, functions called by Do are not detected, nor are
+	// goroutines spawned without tracking.
+	_ = safe.Do(func() error {
+		if safe.InRecoveredContext() {
+			t.Error("InRecoveredContext() = true in Do")
+		}
+		return nil
+	})
+	safe.TrackGoroutines(false)
+	safe.Go(func() { ch <- safe.InRecoveredContext() })
+	if <-ch {
+		t.Error("InRecoveredContext() = true in Go without tracking")
+	}
+}
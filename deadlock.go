@@ -0,0 +1,177 @@
+package safe
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+var deadlockDetection atomic.Bool // whether nested-group deadlocks are reported
+
+// slots records, while deadlock detection is enabled, which goroutines hold a
+// slot of which group and which of them are blocked on another group.
+var slots struct {
+	mu      sync.Mutex
+	holders map[*Group]map[uint64]struct{} // goroutines running each group's functions
+	owner   map[uint64]*Group              // group whose function each goroutine runs
+	blocked map[uint64]blockedOn           // goroutines blocked in Go or waiting
+	warned  map[*Group]bool                // groups already reported as deadlocked
+}
+
+// blockedOn describes what a goroutine is blocked on.
+type blockedOn struct {
+	g      *Group // group the goroutine is blocked on
+	submit bool   // whether it is blocked in Go on g's limit rather than waiting
+}
+
+// SetDeadlockDetection configures whether deadlocks between nested groups with
+// limits are reported. It is disabled by default, as it adds bookkeeping to
+// every function passed to a Group.
+//
+// Such a deadlock occurs when every function holding a slot of a group with a
+// limit set by Group.SetLimit is blocked in Go, Wait or WaitContext on another
+// group, while a function of one of those groups is blocked in Go waiting for a
+// slot of the first group: no slot is ever freed. When enabled, this is
+// detected as soon as it happens and a warning is logged to the fallback
+// logger, along with the stacks of all goroutines, so that the hang does not go
+// unnoticed. The blocked calls themselves keep blocking. A group that is merely
+// saturated by slow functions is not reported.
+func SetDeadlockDetection(enable bool) {
+	deadlockDetection.Store(enable)
+}
+
+// enterSlot records that the calling goroutine runs a function of g, returning
+// its goroutine ID for leaveSlot, or zero if detection is disabled.
+func enterSlot(g *Group) uint64 {
+	if !deadlockDetection.Load() {
+		return 0
+	}
+	id := goroutineID()
+	slots.mu.Lock()
+	defer slots.mu.Unlock()
+	if slots.holders == nil {
+		slots.holders = make(map[*Group]map[uint64]struct{})
+		slots.owner = make(map[uint64]*Group)
+		slots.blocked = make(map[uint64]blockedOn)
+		slots.warned = make(map[*Group]bool)
+	}
+	if slots.holders[g] == nil {
+		slots.holders[g] = make(map[uint64]struct{})
+	}
+	slots.holders[g][id] = struct{}{}
+	slots.owner[id] = g
+	return id
+}
+
+// leaveSlot records that the goroutine with the given ID, as returned by
+// enterSlot, no longer runs a function of g.
+func leaveSlot(g *Group, id uint64) {
+	if id == 0 {
+		return
+	}
+	slots.mu.Lock()
+	defer slots.mu.Unlock()
+	delete(slots.holders[g], id)
+	if len(slots.holders[g]) == 0 {
+		delete(slots.holders, g)
+	}
+	delete(slots.owner, id)
+	delete(slots.warned, g)
+}
+
+// block records that the calling goroutine is about to block on g, reporting a
+// deadlock if that completes one. It returns the goroutine ID for unblock, or
+// zero if detection is disabled.
+func block(g *Group, submit bool) uint64 {
+	if !deadlockDetection.Load() {
+		return 0
+	}
+	id := goroutineID()
+	slots.mu.Lock()
+	if slots.blocked == nil {
+		slots.blocked = make(map[uint64]blockedOn)
+	}
+	slots.blocked[id] = blockedOn{g: g, submit: submit}
+	stuck := deadlockedLocked(g)
+	if stuck == nil {
+		stuck = deadlockedLocked(slots.owner[id])
+	}
+	if stuck != nil {
+		slots.warned[stuck] = true
+	}
+	slots.mu.Unlock()
+
+	if stuck != nil {
+		warnDeadlock()
+	}
+	return id
+}
+
+// unblock records that the goroutine with the given ID, as returned by block,
+// is no longer blocked.
+func unblock(id uint64) {
+	if id == 0 {
+		return
+	}
+	slots.mu.Lock()
+	defer slots.mu.Unlock()
+	delete(slots.blocked, id)
+}
+
+// deadlockedLocked returns g if it is newly deadlocked: all of its slots are
+// held by functions blocked on other groups, and a function of one of those
+// groups is blocked in Go waiting for a slot of g. slots.mu must be held.
+func deadlockedLocked(g *Group) *Group {
+	if g == nil || slots.warned[g] {
+		return nil
+	}
+	g.mu.Lock()
+	limit := g.limit
+	g.mu.Unlock()
+	holders := slots.holders[g]
+	if limit <= 0 || len(holders) < limit {
+		return nil
+	}
+
+	waitedOn := make(map[*Group]bool, len(holders))
+	for id := range holders {
+		b, ok := slots.blocked[id]
+		if !ok || b.g == g {
+			return nil
+		}
+		waitedOn[b.g] = true
+	}
+	for id, b := range slots.blocked {
+		if b.submit && b.g == g && waitedOn[slots.owner[id]] {
+			return g
+		}
+	}
+	return nil
+}
+
+// submit passes fn to the underlying errgroup, recording the call as blocked
+// on g if detection is enabled and g is at its limit.
+func (g *Group) submit(fn func() error) {
+	if deadlockDetection.Load() {
+		if g.g.TryGo(fn) {
+			return
+		}
+		defer unblock(block(g, true))
+	}
+	g.g.Go(fn)
+}
+
+// warnDeadlock logs a warning about a deadlock between nested groups, with the
+// stacks of all goroutines.
+func warnDeadlock() {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	logf("safe: deadlock between nested groups: every function holding a slot of a group with a limit is blocked on a group waiting for one of those slots\n%s\n", buf)
+}
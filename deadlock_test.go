@@ -0,0 +1,88 @@
+package safe_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestSetDeadlockDetection(t *testing.T) {
+	logs := make(chan string, 10)
+	safe.SetFallbackLogger(func(format string, args ...interface{}) {
+		logs <- fmt.Sprintf(format, args...)
+	})
+	t.Cleanup(func() { safe.SetFallbackLogger(nil) })
+	safe.SetDeadlockDetection(true)
+	t.Cleanup(func() { safe.SetDeadlockDetection(false) })
+
+	// The only slot of outer is held by a function waiting on a nested group,
+	// whose function blocks submitting to outer.
+	var outer, inner safe.Group
+	outer.SetLimit(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	outer.Go(func() error {
+		inner.Go(func() error {
+			outer.Go(func() error { return nil })
+			return nil
+		})
+		inner.WaitContext(ctx)
+		return nil
+	})
+
+	select {
+	case msg := <-logs:
+		if !strings.Contains(msg, "deadlock between nested groups") {
+			t.Errorf("got log %q, want a deadlock warning", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no deadlock warning logged")
+	}
+
+	// Giving up on inner frees the slot, letting its function complete.
+	cancel()
+	if err := outer.Wait(); err != nil {
+		t.Errorf("outer.Wait() = %v", err)
+	}
+	if err := inner.Wait(); err != nil {
+		t.Errorf("inner.Wait() = %v", err)
+	}
+}
+
+func TestSetDeadlockDetectionSaturated(t *testing.T) {
+	logs := make(chan string, 10)
+	safe.SetFallbackLogger(func(format string, args ...interface{}) {
+		logs <- fmt.Sprintf(format, args...)
+	})
+	t.Cleanup(func() { safe.SetFallbackLogger(nil) })
+	safe.SetDeadlockDetection(true)
+	t.Cleanup(func() { safe.SetDeadlockDetection(false) })
+
+	// Slow functions saturating the limit, each also waiting on a nested group,
+	// block further submissions without deadlocking.
+	var g safe.Group
+	g.SetLimit(2)
+	for i := 0; i < 6; i++ {
+		g.Go(func() error {
+			var inner safe.Group
+			inner.Go(func() error {
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			})
+			return inner.Wait()
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Errorf("Wait() = %v", err)
+	}
+
+	select {
+	case msg := <-logs:
+		t.Errorf("got log %q, want none", msg)
+	default:
+	}
+}
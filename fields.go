@@ -0,0 +1,71 @@
+package safe
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// field is a key-value pair attached to a PanicError. Fields form an
+// immutable linked list so that PanicError remains comparable and cheap to
+// copy.
+type field struct {
+	key   string
+	value interface{}
+	next  *field
+}
+
+// WithField returns a copy of p with the given field attached, replacing any
+// existing field with the same key. Fields carry context such as request
+// metadata to panic handlers, and are included in the %+v and JSON output.
+func (p PanicError) WithField(key string, value interface{}) PanicError {
+	p.fields = &field{key: key, value: value, next: p.fields}
+	return p
+}
+
+// Fields returns the fields attached to p, or nil if there are none.
+func (p PanicError) Fields() map[string]interface{} {
+	if p.fields == nil {
+		return nil
+	}
+	m := make(map[string]interface{})
+	for f := p.fields; f != nil; f = f.next {
+		if _, ok := m[f.key]; !ok {
+			m[f.key] = f.value
+		}
+	}
+	return m
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GoWithFields is like Go, but attaches the given fields to the
+// safe.PanicError passed to the global panic handler if a panic occurs.
+func GoWithFields(fields map[string]interface{}, fn func()) {
+	if fn == nil {
+		reportPanic(errors.WithStack(ErrNilFunc))
+		return
+	}
+	pc := caller()
+	spawn(pc, func() {
+		defer func() {
+			if r := recover(); r != nil {
+				p := panicError(r)
+				p.spawnPC = pc
+				for k, v := range fields {
+					p = p.WithField(k, v)
+				}
+				handlePanicError(p)
+			}
+		}()
+		fn()
+	})
+}
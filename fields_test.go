@@ -0,0 +1,37 @@
+package safe_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestGoWithFields(t *testing.T) {
+	errs := handlePanics(t)
+	safe.TrackGoroutines(true)
+	t.Cleanup(func() { safe.TrackGoroutines(false) })
+
+	safe.GoWithFields(map[string]interface{}{"request": 42}, func() {
+		if !safe.InRecoveredContext() {
+			t.Error("InRecoveredContext() = false in GoWithFields")
+		}
+		panic("boom")
+	})
+	if err := safe.WaitAll(context.Background()); err != nil {
+		t.Fatalf("WaitAll() = %v", err)
+	}
+	p := requirePanicError(t, <-errs, "boom")
+	if got := p.Fields()["request"]; got != 42 {
+		t.Errorf("field request = %v, want 42", got)
+	}
+}
+
+func TestGoWithFieldsNil(t *testing.T) {
+	errs := handlePanics(t)
+	safe.GoWithFields(nil, nil)
+	if err := <-errs; !errors.Is(err, safe.ErrNilFunc) {
+		t.Errorf("got %v, want %v", err, safe.ErrNilFunc)
+	}
+}
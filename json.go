@@ -0,0 +1,62 @@
+package safe
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// panicErrorJSON is the JSON representation of a PanicError.
+type panicErrorJSON struct {
+	Message    string                 `json:"message"`
+	PanicValue string                 `json:"panic_value"`
+	PanicType  string                 `json:"panic_type"`
+	Stack      errors.StackTrace      `json:"stack"`
+	Time       *time.Time             `json:"time,omitempty"`
+	SpawnedAt  string                 `json:"spawned_at,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+	Metadata   map[string]string      `json:"metadata,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. The stack trace is rendered as an
+// array of "function file:line" strings, from the innermost frame outwards.
+func (p PanicError) MarshalJSON() ([]byte, error) {
+	// Format values with fmt, which recovers from panics in their methods.
+	v := panicErrorJSON{
+		Message:    fmt.Sprint(p),
+		PanicValue: fmt.Sprint(p.val),
+		PanicType:  fmt.Sprintf("%T", p.val),
+	}
+	if p.pkgError != nil {
+		v.Stack = p.StackTrace()
+	}
+	if !p.at.IsZero() {
+		v.Time = &p.at
+	}
+	v.SpawnedAt, _ = p.SpawnedAt()
+	v.Fields = p.Fields()
+	v.Metadata = p.Metadata()
+	if b, err := marshalRecover(v); err == nil {
+		return b, nil
+	}
+
+	// Fall back to the string form of any fields that cannot be marshaled.
+	for k, val := range v.Fields {
+		v.Fields[k] = fmt.Sprint(val)
+	}
+	return json.Marshal(v)
+}
+
+// marshalRecover is like json.Marshal, but returns an error if a value's
+// MarshalJSON method panics, which json.Marshal does not recover from. The
+// panic is not reported or counted, since it is not an application panic.
+func marshalRecover(v interface{}) (b []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic marshaling JSON: %v", r)
+		}
+	}()
+	return json.Marshal(v)
+}
@@ -0,0 +1,72 @@
+package safe_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+type panickingMarshaler struct{}
+
+func (panickingMarshaler) MarshalJSON() ([]byte, error) { panic("marshal") }
+
+func (panickingMarshaler) String() string { return "unmarshalable" }
+
+func TestPanicErrorMarshalJSONPanickingField(t *testing.T) {
+	err := safe.Do(func() error { panic("boom") })
+	var p safe.PanicError
+	if !errors.As(err, &p) {
+		t.Fatalf("got error %v, want a PanicError", err)
+	}
+	p = p.WithField("value", panickingMarshaler{})
+
+	count := safe.PanicCount()
+	b, err := json.Marshal(p)
+	if n := safe.PanicCount() - count; n != 0 {
+		t.Errorf("marshaling counted %d panics, want 0", n)
+	}
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	var v struct {
+		PanicValue string            `json:"panic_value"`
+		Fields     map[string]string `json:"fields"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("json.Unmarshal(%s) = %v", b, err)
+	}
+	if v.PanicValue != "boom" || v.Fields["value"] != "unmarshalable" {
+		t.Errorf("got %s, want the field in its fmt.Sprint form", b)
+	}
+}
+
+func TestPanicErrorMarshalJSONMetadata(t *testing.T) {
+	md := map[string]string{"version": "1.2.3", "host": "web-1"}
+	safe.SetReportMetadata(md)
+	t.Cleanup(func() { safe.SetReportMetadata(nil) })
+	// Later changes to md have no effect.
+	md["version"] = "changed"
+
+	b, err := json.Marshal(safe.Do(func() error { panic("boom") }))
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	var v struct {
+		Metadata map[string]string `json:"metadata"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("json.Unmarshal(%s) = %v", b, err)
+	}
+	if v.Metadata["version"] != "1.2.3" || v.Metadata["host"] != "web-1" {
+		t.Errorf("got metadata %v, want version 1.2.3 and host web-1", v.Metadata)
+	}
+
+	safe.SetReportMetadata(nil)
+	b, _ = json.Marshal(safe.Do(func() error { panic("boom") }))
+	if strings.Contains(string(b), `"metadata"`) {
+		t.Errorf("got %s, want no metadata once cleared", b)
+	}
+}
@@ -0,0 +1,33 @@
+package safe
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// GoLabeled is like Go, but runs fn with the given pprof labels attached to
+// its goroutine, as with pprof.Do, so that it can be identified in goroutine
+// and CPU profiles. If a panic occurs, the labels are also attached as fields
+// to the safe.PanicError passed to the global panic handler.
+func GoLabeled(labels map[string]string, fn func()) {
+	pc := caller()
+	kvs := make([]string, 0, 2*len(labels))
+	for k, v := range labels {
+		kvs = append(kvs, k, v)
+	}
+	spawn(pc, func() {
+		pprof.Do(context.Background(), pprof.Labels(kvs...), func(context.Context) {
+			defer func() {
+				if r := recover(); r != nil {
+					p := panicError(r)
+					p.spawnPC = pc
+					for k, v := range labels {
+						p = p.WithField(k, v)
+					}
+					handlePanicError(p)
+				}
+			}()
+			fn()
+		})
+	})
+}
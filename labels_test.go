@@ -0,0 +1,37 @@
+package safe_test
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestGoLabeled(t *testing.T) {
+	errs := handlePanics(t)
+	running := make(chan struct{})
+	release := make(chan struct{})
+	safe.GoLabeled(map[string]string{"job": "labeled-test"}, func() {
+		close(running)
+		<-release
+		panic("boom")
+	})
+
+	<-running
+	// fn has no context to pass to pprof.ForLabels, so look for its labels
+	// in the goroutine profile, which lists them per goroutine.
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if !bytes.Contains(buf.Bytes(), []byte(`"job":"labeled-test"`)) {
+		t.Errorf("goroutine profile has no goroutine labeled job=labeled-test:\n%s", buf.Bytes())
+	}
+
+	p := requirePanicError(t, <-errs, "boom")
+	if got := p.Fields()["job"]; got != "labeled-test" {
+		t.Errorf("field job = %v, want labeled-test", got)
+	}
+}
@@ -0,0 +1,34 @@
+package safe
+
+import "sync/atomic"
+
+var reportMetadata atomic.Pointer[map[string]string] // metadata for new panics
+
+// SetReportMetadata configures metadata, such as the service version and
+// hostname, that is attached to every PanicError created afterwards. It is
+// available from PanicError.Metadata and included in its JSON output. If md is
+// empty, no metadata is attached.
+//
+// md is copied, so later changes to it have no effect. The copy is shared by
+// all PanicErrors rather than copied per panic.
+func SetReportMetadata(md map[string]string) {
+	if len(md) == 0 {
+		reportMetadata.Store(nil)
+		return
+	}
+	cp := make(map[string]string, len(md))
+	for k, v := range md {
+		cp[k] = v
+	}
+	reportMetadata.Store(&cp)
+}
+
+// Metadata returns the metadata configured with SetReportMetadata when the
+// panic was recovered, or nil if none. The returned map is shared and must not
+// be modified.
+func (p PanicError) Metadata() map[string]string {
+	if p.md == nil {
+		return nil
+	}
+	return *p.md
+}
@@ -0,0 +1,41 @@
+package safe
+
+import "sync/atomic"
+
+var (
+	panicCount atomic.Uint64 // number of recovered panics
+	onPanic    atomic.Value  // callback for each recovered panic
+)
+
+// PanicCount returns the total number of panics recovered by this package,
+// including those recovered by Do, Go and Group.Go.
+func PanicCount() uint64 {
+	return panicCount.Load()
+}
+
+// OnPanic configures a callback that is called each time a panic is recovered
+// by this package, e.g. to increment a metrics counter. It replaces any
+// previously configured callback; passing nil removes it.
+//
+// fn is called synchronously on the recovering goroutine and should be fast.
+func OnPanic(fn func()) {
+	onPanic.Store(fn)
+}
+
+// countPanic records a recovered panic.
+func countPanic() {
+	panicCount.Add(1)
+	spendPanicBudget()
+	fn, _ := onPanic.Load().(func())
+	if fn == nil {
+		return
+	}
+
+	// Catch panics in the callback.
+	defer func() {
+		if r := recover(); r != nil {
+			logf("panic in OnPanic callback: %v\n", r)
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,30 @@
+package safe_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestPanicCount(t *testing.T) {
+	const n = 10
+	errs := handlePanics(t)
+	var calls atomic.Int32
+	safe.OnPanic(func() { calls.Add(1) })
+	t.Cleanup(func() { safe.OnPanic(nil) })
+
+	before := safe.PanicCount()
+	for i := 0; i < n; i++ {
+		safe.Go(func() { panic("boom") })
+	}
+	for i := 0; i < n; i++ {
+		<-errs
+	}
+	if got := safe.PanicCount() - before; got != n {
+		t.Errorf("PanicCount() increased by %d, want %d", got, n)
+	}
+	if got := calls.Load(); got != n {
+		t.Errorf("OnPanic callback called %d times, want %d", got, n)
+	}
+}
@@ -0,0 +1,31 @@
+package safe
+
+import "github.com/pkg/errors"
+
+// Must returns v if err is nil, and otherwise panics with err. It is intended
+// for initialization code where an error is unrecoverable.
+//
+// Unless err already carries a pkg/errors stack trace, it is wrapped with one,
+// so that if the panic is recovered by this package the resulting
+// safe.PanicError points at the call to Must. The original error remains
+// available through errors.Is and errors.As.
+func Must[T any](v T, err error) T {
+	if err == nil {
+		return v
+	}
+	if _, ok := err.(pkgError); !ok {
+		err = errors.WithStack(err)
+	}
+	panic(err)
+}
+
+// Must0 is like Must for functions that only return an error.
+func Must0(err error) {
+	if err == nil {
+		return
+	}
+	if _, ok := err.(pkgError); !ok {
+		err = errors.WithStack(err)
+	}
+	panic(err)
+}
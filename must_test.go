@@ -0,0 +1,52 @@
+package safe_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestMust(t *testing.T) {
+	if v := safe.Must(42, nil); v != 42 {
+		t.Errorf("Must() = %d, want 42", v)
+	}
+
+	err := safe.Do(func() error {
+		safe.Must(0, errPartial)
+		return nil
+	})
+	if !errors.Is(err, errPartial) {
+		t.Errorf("Do() = %v, want it to wrap %v", err, errPartial)
+	}
+	p, ok := safe.AsPanicError(err)
+	if !ok {
+		t.Fatalf("Do() = %v, want a PanicError", err)
+	}
+	if !errors.Is(p.Unwrap(), errPartial) {
+		t.Errorf("Unwrap() = %v, want the error passed to Must", p.Unwrap())
+	}
+	if fn := fmt.Sprintf("%n", p.StackTrace()[0]); !strings.HasPrefix(fn, "Must") {
+		t.Errorf("got innermost frame %s, want the call to Must", fn)
+	}
+}
+
+func TestMust0(t *testing.T) {
+	safe.Must0(nil)
+	err := safe.Do(func() error {
+		safe.Must0(errPartial)
+		return nil
+	})
+	if !errors.Is(err, errPartial) {
+		t.Errorf("Do() = %v, want it to wrap %v", err, errPartial)
+	}
+	p, ok := safe.AsPanicError(err)
+	if !ok {
+		t.Fatalf("Do() = %v, want a PanicError", err)
+	}
+	if fn := fmt.Sprintf("%n", p.StackTrace()[0]); fn != "Must0" {
+		t.Errorf("got innermost frame %s, want the call to Must0", fn)
+	}
+}
@@ -0,0 +1,62 @@
+package safe
+
+import (
+	stderrors "errors"
+	"sync"
+)
+
+// ErrGoexit is returned by Once.Do to the callers waiting for a call whose
+// function called runtime.Goexit.
+var ErrGoexit = stderrors.New("safe: runtime.Goexit called")
+
+// A Once deduplicates concurrent calls for the same key, like
+// golang.org/x/sync/singleflight, so that an expensive computation runs once
+// and its result is shared by all callers waiting for it. If the computation
+// panics, the panic is recovered and returned as a safe.PanicError to every
+// waiting caller.
+//
+// Results are not cached: once a call completes, whether it succeeded, failed
+// or panicked, the next call for its key runs the function again.
+//
+// A zero Once is ready to use. A Once must not be copied after first use.
+type Once[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*onceCall[T] // in-flight calls by key
+}
+
+// onceCall is an in-flight or completed call of Once.Do.
+type onceCall[T any] struct {
+	done chan struct{} // closed when the call completes
+	res  T
+	err  error
+}
+
+// Do calls fn and returns its results, making sure that only one call for the
+// given key is in flight at a time. If a call for key is already in flight, Do
+// waits for it to complete and returns its results instead of calling fn.
+func (o *Once[T]) Do(key string, fn func() (T, error)) (T, error) {
+	o.mu.Lock()
+	if c, ok := o.calls[key]; ok {
+		o.mu.Unlock()
+		<-c.done
+		return c.res, c.err
+	}
+	c := &onceCall[T]{done: make(chan struct{})}
+	if o.calls == nil {
+		o.calls = make(map[string]*onceCall[T])
+	}
+	o.calls[key] = c
+	o.mu.Unlock()
+
+	// Complete the call even if fn calls runtime.Goexit, so that waiting
+	// callers do not hang.
+	c.err = ErrGoexit
+	defer func() {
+		o.mu.Lock()
+		delete(o.calls, key)
+		o.mu.Unlock()
+		close(c.done)
+	}()
+	c.res, c.err = DoTyped(fn)
+	return c.res, c.err
+}
@@ -0,0 +1,97 @@
+package safe_test
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+// doConcurrently calls o.Do for key with fn from n goroutines at once, letting
+// fn return only after all of them had time to call o.Do. It returns the
+// results of each call.
+func doConcurrently(o *safe.Once[int], key string, n int, fn func() (int, error)) ([]int, []error) {
+	var (
+		wg      sync.WaitGroup
+		res     = make([]int, n)
+		errs    = make([]error, n)
+		release = make(chan struct{})
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res[i], errs[i] = o.Do(key, func() (int, error) {
+				<-release
+				return fn()
+			})
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	return res, errs
+}
+
+func TestOnceDo(t *testing.T) {
+	var (
+		o     safe.Once[int]
+		calls atomic.Int32
+	)
+	res, errs := doConcurrently(&o, "key", 10, func() (int, error) {
+		calls.Add(1)
+		return 42, nil
+	})
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn called %d times, want 1", n)
+	}
+	for i := range res {
+		if res[i] != 42 || errs[i] != nil {
+			t.Errorf("caller %d got %v, %v, want 42, nil", i, res[i], errs[i])
+		}
+	}
+
+	// Results are not cached.
+	o.Do("key", func() (int, error) {
+		calls.Add(1)
+		return 0, nil
+	})
+	if n := calls.Load(); n != 2 {
+		t.Errorf("fn called %d times after the call completed, want 2", n)
+	}
+}
+
+func TestOnceDoPanic(t *testing.T) {
+	var o safe.Once[int]
+	res, errs := doConcurrently(&o, "key", 10, func() (int, error) {
+		panic("boom")
+	})
+	for i := range res {
+		if res[i] != 0 {
+			t.Errorf("caller %d got result %v, want 0", i, res[i])
+		}
+		requirePanicError(t, errs[i], "boom")
+	}
+}
+
+func TestOnceDoGoexit(t *testing.T) {
+	var o safe.Once[int]
+	res, errs := doConcurrently(&o, "key", 10, func() (int, error) {
+		runtime.Goexit()
+		return 0, nil
+	})
+	// The caller running fn exits, leaving its results unset, and the others
+	// get ErrGoexit instead of hanging.
+	n := 0
+	for i := range res {
+		if errs[i] == safe.ErrGoexit {
+			n++
+		}
+	}
+	if n != len(res)-1 {
+		t.Errorf("%d callers got ErrGoexit, want %d", n, len(res)-1)
+	}
+}
@@ -0,0 +1,90 @@
+package safe
+
+import (
+	"context"
+	"sync"
+)
+
+// ForEach calls fn for each of items concurrently, with at most limit calls
+// active at once. A limit of zero or less means there is no limit. If any call
+// panics, it will be recovered and returned as a safe.PanicError.
+//
+// The first call to panic or return a non-nil error cancels the context passed
+// to the other calls, stops any further items from being started, and is
+// returned once all active calls have returned.
+func ForEach[T any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) error) error {
+	return forEachIndex(ctx, len(items), limit, func(ctx context.Context, i int) error {
+		return fn(ctx, items[i])
+	})
+}
+
+// Map is like ForEach, but returns the results of fn in the same order as
+// items. If an error is returned, results of items that were not processed or
+// that failed are left as the zero value of R.
+func Map[T, R any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) (R, error)) ([]R, error) {
+	results := make([]R, len(items))
+	err := forEachIndex(ctx, len(items), limit, func(ctx context.Context, i int) error {
+		res, err := fn(ctx, items[i])
+		if err != nil {
+			return err
+		}
+		results[i] = res
+		return nil
+	})
+	return results, err
+}
+
+// Consume starts the given number of workers, which must be at least one, each
+// receiving jobs from the channel and calling fn for them, and blocks until all
+// workers have exited. Workers exit once jobs is closed and drained, or ctx is
+// canceled. If a call panics, it will be recovered and passed to the global
+// panic handler, and the worker continues with the next job.
+func Consume[T any](ctx context.Context, jobs <-chan T, workers int, fn func(ctx context.Context, job T)) {
+	if workers < 1 {
+		panic("safe: Consume requires at least one worker")
+	}
+	pc := caller()
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		spawn(pc, func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job, ok := <-jobs:
+					if !ok {
+						return
+					}
+					runFrom(pc, func() {
+						fn(ctx, job)
+					})
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// forEachIndex calls fn for each index from 0 to n-1 as described by ForEach.
+func forEachIndex(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) error {
+	if n == 0 {
+		return nil
+	}
+
+	g, ctx := GroupWithContext(ctx)
+	if limit > 0 {
+		g.SetLimit(limit)
+	}
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		i := i
+		g.Go(func() error {
+			return fn(ctx, i)
+		})
+	}
+	return g.Wait()
+}
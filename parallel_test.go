@@ -0,0 +1,164 @@
+package safe_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestConsumePanics(t *testing.T) {
+	const workers, n = 4, 100
+	errs := handlePanics(t)
+	jobs := make(chan int)
+	started := make(chan struct{}, workers)
+	release := make(chan struct{})
+	go func() {
+		defer close(jobs)
+		for i := 0; i < n; i++ {
+			jobs <- i
+		}
+	}()
+	go func() {
+		// Once each worker has panicked, all of them must still be consuming
+		// to run the next jobs at once.
+		for i := 0; i < workers; i++ {
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Errorf("only %d of %d workers running after panics", i, workers)
+			}
+		}
+		close(release)
+	}()
+
+	var done atomic.Int32
+	safe.Consume(context.Background(), jobs, workers, func(_ context.Context, job int) {
+		switch {
+		case job < workers:
+			panic(job)
+		case job < 2*workers:
+			started <- struct{}{}
+			<-release
+		}
+		done.Add(1)
+	})
+	if got := done.Load(); got != n-workers {
+		t.Errorf("%d jobs completed, want %d", got, n-workers)
+	}
+	if got := len(errs); got != workers {
+		t.Errorf("%d panics reported, want %d", got, workers)
+	}
+}
+
+func TestForEachNoLimit(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		// Every call must be active at once for any of them to return.
+		const n = 10
+		var wg sync.WaitGroup
+		wg.Add(n)
+		err := safe.ForEach(context.Background(), make([]int, n), limit, func(context.Context, int) error {
+			wg.Done()
+			wg.Wait()
+			return nil
+		})
+		if err != nil {
+			t.Errorf("ForEach() with limit %d = %v", limit, err)
+		}
+	}
+}
+
+func TestForEachLimit(t *testing.T) {
+	const limit = 3
+	var active, peak atomic.Int32
+	err := safe.ForEach(context.Background(), make([]int, 20), limit, func(context.Context, int) error {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		active.Add(-1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach() = %v", err)
+	}
+	if p := peak.Load(); p > limit {
+		t.Errorf("%d calls active at once, want at most %d", p, limit)
+	}
+}
+
+func TestForEachEmpty(t *testing.T) {
+	err := safe.ForEach(context.Background(), nil, 1, func(context.Context, int) error {
+		t.Error("fn called for an empty slice")
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ForEach() = %v", err)
+	}
+	res, err := safe.Map(context.Background(), []int{}, 1, func(context.Context, int) (int, error) {
+		t.Error("fn called for an empty slice")
+		return 0, nil
+	})
+	if len(res) != 0 || err != nil {
+		t.Errorf("Map() = (%v, %v), want no results", res, err)
+	}
+}
+
+func TestForEachError(t *testing.T) {
+	err := safe.ForEach(context.Background(), []int{1, 2, 3}, 1, func(ctx context.Context, i int) error {
+		if i == 2 {
+			return errPartial
+		}
+		return nil
+	})
+	if err != errPartial {
+		t.Errorf("ForEach() = %v, want %v", err, errPartial)
+	}
+
+	// A panic cancels the other calls and is returned.
+	err = safe.ForEach(context.Background(), []int{1, 2, 3}, 0, func(ctx context.Context, i int) error {
+		if i == 2 {
+			panic("boom")
+		}
+		<-ctx.Done()
+		return nil
+	})
+	requirePanicError(t, err, "boom")
+}
+
+func TestMap(t *testing.T) {
+	items := []int{5, 4, 3, 2, 1}
+	res, err := safe.Map(context.Background(), items, 2, func(_ context.Context, i int) (int, error) {
+		// Later items finish first.
+		time.Sleep(time.Duration(i) * time.Millisecond)
+		return i * 10, nil
+	})
+	if err != nil {
+		t.Fatalf("Map() = %v", err)
+	}
+	for i, r := range res {
+		if r != items[i]*10 {
+			t.Errorf("result %d = %d, want %d", i, r, items[i]*10)
+		}
+	}
+}
+
+func TestMapPanic(t *testing.T) {
+	res, err := safe.Map(context.Background(), []int{1, 2, 3}, 1, func(_ context.Context, i int) (string, error) {
+		if i == 2 {
+			panic("boom")
+		}
+		return "ok", nil
+	})
+	requirePanicError(t, err, "boom")
+	if len(res) != 3 || res[0] != "ok" || res[1] != "" {
+		t.Errorf("Map() results = %q, want ok for the first item and none for the panicking one", res)
+	}
+}
@@ -0,0 +1,57 @@
+package safe
+
+import "context"
+
+// A StageFunc is a stage of a Pipeline. It receives values from the previous
+// stage on in and sends values to the next stage on out, returning once in is
+// closed or ctx is done. It must not close out, and should select on
+// ctx.Done() when sending so that it stops if another stage fails.
+type StageFunc func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error
+
+// A Pipeline is a sequence of stages, each running in its own goroutine and
+// connected to the next by a channel. If any stage panics or returns a
+// non-nil error, the context passed to all stages is canceled, so that both
+// upstream and downstream stages stop, and the error is returned by Run.
+type Pipeline struct {
+	ctx    context.Context
+	stages []StageFunc
+}
+
+// NewPipeline returns a new, empty Pipeline whose stages are passed a context
+// derived from ctx.
+func NewPipeline(ctx context.Context) *Pipeline {
+	return &Pipeline{ctx: ctx}
+}
+
+// Stage appends fn to the pipeline. The first stage receives from a closed
+// channel, and values sent by the last stage are discarded. Stage must not be
+// called after Run.
+func (p *Pipeline) Stage(fn StageFunc) {
+	p.stages = append(p.stages, fn)
+}
+
+// Run starts all stages and blocks until they have all returned, then returns
+// the first non-nil error (if any) from them. If a stage panics, the panic is
+// recovered and returned as a safe.PanicError.
+func (p *Pipeline) Run() error {
+	g, ctx := GroupWithContext(p.ctx)
+	next := make(chan interface{})
+	close(next)
+	for _, fn := range p.stages {
+		fn, in, out := fn, next, make(chan interface{})
+		g.Go(func() error {
+			defer close(out)
+			return fn(ctx, in, out)
+		})
+		next = out
+	}
+
+	// Discard the output of the last stage.
+	last := next
+	g.Go(func() error {
+		for range last {
+		}
+		return nil
+	})
+	return g.Wait()
+}
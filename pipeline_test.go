@@ -0,0 +1,55 @@
+package safe_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestPipelinePanic(t *testing.T) {
+	var canceled atomic.Int32
+	p := safe.NewPipeline(context.Background())
+	// An endless source, which only stops once canceled.
+	p.Stage(func(ctx context.Context, _ <-chan interface{}, out chan<- interface{}) error {
+		for i := 0; ; i++ {
+			select {
+			case out <- i:
+			case <-ctx.Done():
+				canceled.Add(1)
+				return ctx.Err()
+			}
+		}
+	})
+	p.Stage(func(ctx context.Context, in <-chan interface{}, out chan<- interface{}) error {
+		for v := range in {
+			if v == 3 {
+				panic("boom")
+			}
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	// A sink, which would wait forever for the middle stage without
+	// cancellation.
+	p.Stage(func(ctx context.Context, in <-chan interface{}, _ chan<- interface{}) error {
+		for {
+			select {
+			case <-in:
+			case <-ctx.Done():
+				canceled.Add(1)
+				return ctx.Err()
+			}
+		}
+	})
+
+	requirePanicError(t, p.Run(), "boom")
+	if n := canceled.Load(); n != 2 {
+		t.Errorf("%d of the other stages were canceled, want 2", n)
+	}
+}
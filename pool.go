@@ -0,0 +1,61 @@
+package safe
+
+import "sync"
+
+// A Pool is a fixed set of long-lived worker goroutines executing submitted
+// functions from a shared queue. Unlike Group, it does not start a goroutine
+// per function, which makes it better suited to high submission rates. If any
+// panics occur, they will be recovered and returned as a safe.PanicError; the
+// worker that recovered the panic keeps serving the queue.
+type Pool struct {
+	tasks     chan func() error
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+	errOnce   sync.Once
+	err       error
+}
+
+// NewPool returns a new Pool with the given number of workers, which must be
+// at least one. The queue holds up to one pending function per worker.
+func NewPool(workers int) *Pool {
+	if workers < 1 {
+		panic("safe: NewPool requires at least one worker")
+	}
+	pc := caller()
+	p := &Pool{tasks: make(chan func() error, workers)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		spawn(pc, p.work)
+	}
+	return p
+}
+
+// work executes queued functions until the queue is closed.
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for fn := range p.tasks {
+		if err := Do(fn); err != nil {
+			p.errOnce.Do(func() {
+				p.err = err
+			})
+		}
+	}
+}
+
+// Submit queues fn to be executed by a worker. It blocks while the queue is
+// full.
+//
+// Submit must not be called after or concurrently with Wait.
+func (p *Pool) Submit(fn func() error) {
+	p.tasks <- fn
+}
+
+// Wait blocks until all submitted functions have returned and stops the
+// workers, then returns the first non-nil error (if any) from them.
+func (p *Pool) Wait() error {
+	p.closeOnce.Do(func() {
+		close(p.tasks)
+	})
+	p.wg.Wait()
+	return p.err
+}
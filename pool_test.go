@@ -0,0 +1,36 @@
+package safe_test
+
+import (
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestPoolPanicKeepsWorkers(t *testing.T) {
+	const workers = 2
+	p := safe.NewPool(workers)
+	for i := 0; i < workers; i++ {
+		p.Submit(func() error { panic("boom") })
+	}
+
+	// Both workers must still be serving the queue to run these at once.
+	started := make(chan struct{}, workers)
+	release := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		p.Submit(func() error {
+			started <- struct{}{}
+			<-release
+			return nil
+		})
+	}
+	for i := 0; i < workers; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d workers running after panics", i, workers)
+		}
+	}
+	close(release)
+	requirePanicError(t, p.Wait(), "boom")
+}
@@ -0,0 +1,48 @@
+package safe
+
+import (
+	"sync"
+	"time"
+)
+
+// A launchRate spaces out events to a fixed rate, as a token bucket holding at
+// most one token.
+type launchRate struct {
+	mu       sync.Mutex
+	interval time.Duration // minimum time between events
+	next     time.Time     // earliest time of the next event
+}
+
+// newLaunchRate returns a launchRate allowing perSecond events per second.
+func newLaunchRate(perSecond float64) *launchRate {
+	return &launchRate{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the next event is allowed, and reserves it.
+func (r *launchRate) wait() {
+	r.mu.Lock()
+	now := getClock().Now()
+	t := r.next
+	if t.Before(now) {
+		t = now
+	}
+	r.next = t.Add(r.interval)
+	r.mu.Unlock()
+
+	if d := t.Sub(now); d > 0 {
+		<-after(d)
+	}
+}
+
+// allow reserves the next event and reports true if it is allowed now, without
+// blocking.
+func (r *launchRate) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := getClock().Now()
+	if now.Before(r.next) {
+		return false
+	}
+	r.next = now.Add(r.interval)
+	return true
+}
@@ -0,0 +1,69 @@
+package safe
+
+import (
+	"sync"
+	"time"
+)
+
+// panicLogLimiter rate limits the default logging of panics.
+var panicLogLimiter struct {
+	mu         sync.Mutex
+	max        int            // maximum logs per message per second
+	start      time.Time      // start of the current window
+	counts     map[string]int // logs per message in the current window
+	suppressed int            // logs suppressed in the current window
+}
+
+// SetPanicLogRateLimit limits how often the same panic message is written to
+// the log when no panic handler is set. Beyond maxPerSecond occurrences of a
+// message within a second, further occurrences are dropped and counted, and a
+// summary of the number of suppressed panics is logged at the end of the
+// second. A value of zero or less removes the limit, which is the default.
+//
+// Panics passed to a configured panic handler are never rate limited.
+func SetPanicLogRateLimit(maxPerSecond int) {
+	l := &panicLogLimiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.max = maxPerSecond
+	l.counts = nil
+}
+
+// allowPanicLog reports whether a panic with the given message may be logged.
+func allowPanicLog(msg string) bool {
+	l := &panicLogLimiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	if l.counts == nil || now.Sub(l.start) >= time.Second {
+		l.start = now
+		l.counts = make(map[string]int)
+	}
+	if l.counts[msg] < l.max {
+		l.counts[msg]++
+		return true
+	}
+
+	if l.suppressed == 0 {
+		time.AfterFunc(time.Second-now.Sub(l.start), logSuppressedPanics)
+	}
+	l.suppressed++
+	return false
+}
+
+// logSuppressedPanics logs a summary of the panics suppressed by the rate
+// limit.
+func logSuppressedPanics() {
+	l := &panicLogLimiter
+	l.mu.Lock()
+	n := l.suppressed
+	l.suppressed = 0
+	l.mu.Unlock()
+	if n > 0 {
+		logf("suppressed %d panics\n", n)
+	}
+}
@@ -0,0 +1,49 @@
+package safe_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestSetPanicLogRateLimit(t *testing.T) {
+	const n, limit = 100, 2
+	logs := make(chan string, n+10)
+	safe.SetFallbackLogger(func(format string, args ...interface{}) {
+		logs <- fmt.Sprintf(format, args...)
+	})
+	t.Cleanup(func() { safe.SetFallbackLogger(nil) })
+	safe.SetPanicLogRateLimit(limit)
+	t.Cleanup(func() { safe.SetPanicLogRateLimit(0) })
+
+	for i := 0; i < n; i++ {
+		panicAndHandle("boom")
+	}
+
+	// Every panic is either logged or counted in a summary at the end of its
+	// window.
+	logged, suppressed := 0, 0
+	timeout := time.After(5 * time.Second)
+	for logged+suppressed < n {
+		select {
+		case msg := <-logs:
+			var k int
+			if _, err := fmt.Sscanf(msg, "suppressed %d panics", &k); err == nil {
+				suppressed += k
+			} else if strings.HasPrefix(msg, "panic: boom") {
+				logged++
+			} else {
+				t.Fatalf("unexpected log %q", msg)
+			}
+		case <-timeout:
+			t.Fatalf("got %d logged and %d suppressed panics, want %d in total", logged, suppressed, n)
+		}
+	}
+	// The burst may straddle two windows.
+	if logged > 2*limit {
+		t.Errorf("%d panics logged, want at most %d", logged, 2*limit)
+	}
+}
@@ -0,0 +1,143 @@
+package safe
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// A ResultGroup is a Group whose functions each produce a result of type T.
+// Results are returned from Wait in submission order, regardless of the order
+// in which the functions complete.
+//
+// A zero ResultGroup is valid and does not cancel on error.
+type ResultGroup[T any] struct {
+	g       Group
+	mu      sync.Mutex
+	results []T
+	ok      []bool // whether each function succeeded
+
+	maxBytes int         // limit on the total size of results, if size is set
+	size     func(T) int // estimates the size of a result
+	bytes    int         // total estimated size of results
+	exceeded bool        // whether maxBytes was exceeded
+}
+
+// ErrMaxResultBytes is returned by ResultGroup.Wait if the results of its
+// functions exceed the limit configured with SetMaxResultBytes.
+var ErrMaxResultBytes = stderrors.New("safe: result size limit exceeded")
+
+// ResultGroupWithContext returns a new ResultGroup and an associated Context
+// derived from ctx.
+//
+// The derived Context is canceled the first time a function passed to Go
+// panics or returns a non-nil error or the first time Wait returns, whichever
+// occurs first.
+func ResultGroupWithContext[T any](ctx context.Context) (*ResultGroup[T], context.Context) {
+	eg, ctx := errgroup.WithContext(ctx)
+	return &ResultGroup[T]{g: Group{g: eg}}, ctx
+}
+
+// Go calls the given function in a new goroutine and records its result in
+// the slot matching the order of this call.
+//
+// The first call to panic or return a non-nil error cancels the group; its
+// error will be returned by Wait.
+func (g *ResultGroup[T]) Go(fn func() (T, error)) {
+	g.mu.Lock()
+	i := len(g.results)
+	var zero T
+	g.results = append(g.results, zero)
+	g.ok = append(g.ok, false)
+	g.mu.Unlock()
+
+	g.g.Go(func() error {
+		if g.overLimit() {
+			return ErrMaxResultBytes
+		}
+		res, err := fn()
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if err := g.reserveLocked(res); err != nil {
+			return err
+		}
+		g.results[i] = res
+		g.ok[i] = err == nil
+		return err
+	})
+}
+
+// SetMaxResultBytes limits the total size of the results held by the group to
+// max bytes, as estimated by size for each result. This bounds the memory used
+// by large fan-outs.
+//
+// A function whose result would exceed the limit fails with ErrMaxResultBytes
+// instead, canceling the group, and its result is discarded. Functions passed
+// to Go after the limit was exceeded are not called and fail in the same way.
+// SetMaxResultBytes must be called before any calls to Go.
+func (g *ResultGroup[T]) SetMaxResultBytes(max int, size func(T) int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.maxBytes, g.size = max, size
+}
+
+// overLimit reports whether the result size limit has been exceeded.
+func (g *ResultGroup[T]) overLimit() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.exceeded
+}
+
+// reserveLocked accounts for the size of res, returning ErrMaxResultBytes if it
+// exceeds the limit.
+func (g *ResultGroup[T]) reserveLocked(res T) error {
+	if g.size == nil {
+		return nil
+	}
+	n := g.size(res)
+	if g.exceeded || g.bytes+n > g.maxBytes {
+		g.exceeded = true
+		return ErrMaxResultBytes
+	}
+	g.bytes += n
+	return nil
+}
+
+// SetPanicHandler configures a handler for any panics that occur in functions
+// passed to this group's Go method. The handler is called in addition to the
+// panic being returned from Wait.
+func (g *ResultGroup[T]) SetPanicHandler(fn func(err error)) {
+	g.g.SetPanicHandler(fn)
+}
+
+// Wait blocks until all function calls from the Go method have returned, then
+// returns their results in submission order along with the first non-nil error
+// (if any) from them.
+//
+// Results of functions that panicked are left as the zero value of T. Results
+// of functions that completed before the group was canceled are retained.
+func (g *ResultGroup[T]) Wait() ([]T, error) {
+	err := g.g.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.results, err
+}
+
+// WaitMap is like Wait, but returns only the results of the functions that
+// succeeded, keyed by the index of their submission. Functions that returned an
+// error or panicked are absent from the map, so partial results can still be
+// matched to their inputs.
+func (g *ResultGroup[T]) WaitMap() (map[int]T, error) {
+	err := g.g.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	m := make(map[int]T)
+	for i, res := range g.results {
+		if g.ok[i] {
+			m[i] = res
+		}
+	}
+	return m, err
+}
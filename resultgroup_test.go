@@ -0,0 +1,61 @@
+package safe_test
+
+import (
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestResultGroupSetMaxResultBytes(t *testing.T) {
+	var g safe.ResultGroup[string]
+	g.SetMaxResultBytes(10, func(s string) int { return len(s) })
+	for i := 0; i < 3; i++ {
+		g.Go(func() (string, error) { return "abcd", nil })
+	}
+	results, err := g.Wait()
+	if err != safe.ErrMaxResultBytes {
+		t.Errorf("Wait() = %v, want ErrMaxResultBytes", err)
+	}
+	kept := 0
+	for _, res := range results {
+		if res != "" {
+			kept++
+		}
+	}
+	if kept != 2 {
+		t.Errorf("kept %d results within the limit, want 2: %q", kept, results)
+	}
+
+	// Functions submitted once the limit is exceeded are not called.
+	called := false
+	g.Go(func() (string, error) {
+		called = true
+		return "", nil
+	})
+	if _, err := g.Wait(); err != safe.ErrMaxResultBytes || called {
+		t.Errorf("Wait() = %v, called fn: %v; want ErrMaxResultBytes without calling fn", err, called)
+	}
+}
+
+func TestResultGroupWaitMap(t *testing.T) {
+	var g safe.ResultGroup[int]
+	for i := 0; i < 5; i++ {
+		i := i
+		g.Go(func() (int, error) {
+			switch i {
+			case 1:
+				return -1, errPartial
+			case 3:
+				panic("boom")
+			}
+			return i * 10, nil
+		})
+	}
+	m, err := g.WaitMap()
+	if err == nil {
+		t.Error("WaitMap() returned no error")
+	}
+	if len(m) != 3 || m[0] != 0 || m[2] != 20 || m[4] != 40 {
+		t.Errorf("WaitMap() = %v, want map[0:0 2:20 4:40]", m)
+	}
+}
@@ -0,0 +1,46 @@
+package safe
+
+import (
+	"context"
+	"time"
+)
+
+// Retry calls fn up to attempts times until it returns nil, recovering any
+// panics and treating them as retryable errors. If all attempts fail, the last
+// error is returned, which is a safe.PanicError if the final attempt panicked.
+//
+// fn is always called at least once.
+func Retry(attempts int, fn func() error) error {
+	return RetryWithBackoff(context.Background(), attempts, nil, fn)
+}
+
+// RetryWithBackoff is like Retry, but waits for backoff(attempt) between
+// attempts, starting at 1 after the first failure. If backoff is nil, fn is
+// retried immediately. If ctx is canceled before fn succeeds, ctx.Err() is
+// returned.
+func RetryWithBackoff(ctx context.Context, attempts int, backoff func(attempt int) time.Duration, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := Do(fn); err == nil || attempt >= attempts {
+			return err
+		}
+		if backoff == nil {
+			continue
+		}
+		if err := sleep(ctx, backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for d to elapse, returning ctx.Err() early if ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-after(d):
+		return nil
+	}
+}
@@ -3,16 +3,34 @@
 package safe
 
 import (
+	"bytes"
 	"context"
+	stderrors "errors"
 	"fmt"
+	"io"
 	"log"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 )
 
+// ErrPanic is matched by errors.Is for any PanicError, regardless of the
+// underlying panic value.
+var ErrPanic = stderrors.New("panic")
+
+// ErrNilFunc is returned by Do, DoTyped and DoWithResult when called with a nil
+// function, instead of panicking. Go passes it to the global panic handler, and
+// Group.Go returns it from Wait, like any other error.
+var ErrNilFunc = stderrors.New("safe: nil function")
+
 // pkgError represents an error returned from pkg/errors containing a stack
 // trace.
 type pkgError interface {
@@ -21,12 +39,46 @@ type pkgError interface {
 	StackTrace() errors.StackTrace
 }
 
+// stackError is a pkgError with a message and an existing stack trace. It is
+// formatted in the same way as errors created by pkg/errors. It is used by
+// pointer so that a PanicError embedding it remains comparable.
+type stackError struct {
+	msg   string
+	stack errors.StackTrace
+}
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) StackTrace() errors.StackTrace { return e.stack }
+
+func (e *stackError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, e.msg)
+			e.stack.Format(s, verb)
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, e.msg)
+	case 'q':
+		fmt.Fprintf(s, "%q", e.msg)
+	}
+}
+
 // PanicError is an error that wraps a panic value. It also embeds a pkg/errors
 // error to ensure the stack trace is properly captured and rendered to any
 // error reporters.
 type PanicError struct {
-	pkgError             // embedded pkg/errors error with stack trace
-	val      interface{} // panic value
+	pkgError                    // embedded pkg/errors error with stack trace
+	val      interface{}        // panic value
+	spawnPC  uintptr            // program counter of the call spawning the goroutine
+	name     string             // name of the task that panicked, if any
+	goid     uint64             // ID of the goroutine that panicked, if captured
+	fields   *field             // fields attached with WithField, newest first
+	at       time.Time          // time at which the panic was recovered
+	md       *map[string]string // metadata set with SetReportMetadata
 }
 
 // Panic returns the underlying value passed to panic().
@@ -34,16 +86,312 @@ func (p PanicError) Panic() interface{} {
 	return p.val
 }
 
+// GoroutineID returns the ID of the goroutine in which the panic was recovered,
+// as shown in stack traces, or zero if it was not captured. It is only captured
+// along with the stack trace; see SetCaptureStack.
+func (p PanicError) GoroutineID() uint64 {
+	return p.goid
+}
+
+// Time returns the time at which the panic was recovered, according to the
+// Clock configured with SetClock.
+func (p PanicError) Time() time.Time {
+	return p.at
+}
+
+// Name returns the name of the Group task that panicked, as passed to
+// Group.GoNamed, or an empty string if the task was not named.
+func (p PanicError) Name() string {
+	return p.name
+}
+
+// Canceled reports whether the panic value is, or wraps, context.Canceled or
+// context.DeadlineExceeded. Such panics usually represent an expected
+// cancellation rather than a bug.
+func (p PanicError) Canceled() bool {
+	err, ok := p.val.(error)
+	return ok && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
+// Format implements fmt.Formatter. %v and %s write the single-line message
+// "panic: <value>", and %q writes it quoted. %+v writes the message followed by
+// the stack trace, the time of the panic, the task name, fields and spawn site,
+// if any, and notes whether the panic represents a context cancellation.
+func (p PanicError) Format(s fmt.State, verb rune) {
+	if p.pkgError == nil {
+		return
+	}
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, p.Error())
+			p.StackTrace().Format(s, verb)
+			p.formatDetails(s)
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, p.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", p.Error())
+	}
+}
+
+// formatDetails writes the details following the stack trace in %+v output.
+func (p PanicError) formatDetails(s fmt.State) {
+	if !p.at.IsZero() {
+		fmt.Fprintf(s, "\ntime: %s", p.at.Format(time.RFC3339Nano))
+	}
+	if p.name != "" {
+		fmt.Fprintf(s, "\ntask: %s", p.name)
+	}
+	if fields := p.Fields(); fields != nil {
+		io.WriteString(s, "\nfields:")
+		for _, k := range sortedKeys(fields) {
+			fmt.Fprintf(s, " %s=%v", k, fields[k])
+		}
+	}
+	if at, ok := p.SpawnedAt(); ok {
+		fmt.Fprintf(s, "\nspawned at: %s", at)
+	}
+	if p.Canceled() {
+		io.WriteString(s, "\ncause: context cancellation")
+	}
+}
+
+// StackString returns the stack trace of the panic formatted as with %+v, one
+// frame per function and file:line pair, without the error message. It returns
+// an empty string if no stack trace was captured.
+func (p PanicError) StackString() string {
+	return strings.TrimPrefix(fmt.Sprintf("%+v", p.StackTrace()), "\n")
+}
+
+// SpawnedAt returns the "file:line" location of the call to safe.Go (or a
+// similar function) that spawned the goroutine in which the panic occurred. It
+// reports false if the panic did not occur in such a goroutine.
+func (p PanicError) SpawnedAt() (string, bool) {
+	if p.spawnPC == 0 {
+		return "", false
+	}
+	return location(p.spawnPC), true
+}
+
+// location returns the "file:line" location of the call at pc.
+func location(pc uintptr) string {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+}
+
+// Unwrap returns the panic value if it is an error, allowing errors.Is and
+// errors.As to match against the originally panicked error. Otherwise, it
+// returns the embedded error carrying the stack trace.
+func (p PanicError) Unwrap() error {
+	if err, ok := p.val.(error); ok {
+		return err
+	}
+	return p.pkgError
+}
+
+// Is reports whether target is ErrPanic, so that errors.Is(err, ErrPanic)
+// matches any PanicError.
+func (p PanicError) Is(target error) bool {
+	return target == ErrPanic
+}
+
+// IsPanic reports whether any error in err's chain is a PanicError.
+func IsPanic(err error) bool {
+	_, ok := AsPanicError(err)
+	return ok
+}
+
+// AsPanicError finds the first PanicError in err's chain and returns it.
+func AsPanicError(err error) (*PanicError, bool) {
+	var p PanicError
+	if !errors.As(err, &p) {
+		return nil, false
+	}
+	return &p, true
+}
+
+// PanicValueAs finds the first PanicError in err's chain and returns its panic
+// value asserted to type T. It reports false if there is no PanicError or its
+// panic value is not a T.
+func PanicValueAs[T any](err error) (T, bool) {
+	p, ok := AsPanicError(err)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	v, ok := p.val.(T)
+	return v, ok
+}
+
+// ToPlainError converts err into a plain error for interop with error reporters
+// that do not understand PanicError or fmt.Formatter. If err is or wraps a
+// PanicError, the result is a simple error whose message is that of err
+// followed by the stack trace of the panic on subsequent lines, as returned by
+// StackString. Otherwise, err is returned unchanged.
+func ToPlainError(err error) error {
+	p, ok := AsPanicError(err)
+	if !ok {
+		return err
+	}
+	msg := err.Error()
+	if stack := p.StackString(); stack != "" {
+		msg += "\n" + stack
+	}
+	return stderrors.New(msg)
+}
+
 // panicError creates a new PanicError for the given panic value.
-func panicError(val interface{}) error {
-	// Generate a pkg/errors error to capture the stack trace.
-	err := errors.Errorf("panic: %v", val).(pkgError)
-	return PanicError{err, val}
+func panicError(val interface{}) PanicError {
+	countPanic()
+
+	p := PanicError{val: val, at: getClock().Now(), md: reportMetadata.Load()}
+	if err, ok := val.(pkgError); ok {
+		// Reuse the stack trace of errors that already carry one, since it
+		// points at the real failure rather than the recover site.
+		p.pkgError = &stackError{fmt.Sprintf("panic: %v", val), err.StackTrace()}
+	} else if noStack.Load() {
+		p.pkgError = &stackError{msg: fmt.Sprintf("panic: %v", val)}
+		return p
+	} else {
+		// Generate a pkg/errors error to capture the stack trace.
+		p.pkgError = errors.Errorf("panic: %v", val).(pkgError)
+		if n := int(stackSkip.Load()); n > 0 {
+			st := p.StackTrace()
+			p.pkgError = &stackError{p.Error(), st[min(n, len(st)):]}
+		}
+	}
+	if n := int(maxStackDepth.Load()); n > 0 {
+		if st := p.StackTrace(); len(st) > n {
+			p.pkgError = &stackError{p.Error(), append(st[:n:n], truncatedFrame())}
+		}
+	}
+	p.goid = goroutineID()
+	return p
+}
+
+var stackSkip atomic.Int32 // number of leading stack frames to trim
+
+// SetStackSkip configures the number of leading frames trimmed from the stack
+// traces captured when panics are recovered. The innermost frames of a
+// captured stack trace are inside this package and the runtime, so trimming
+// them makes the first frame the site of the panic. If the stack trace has
+// fewer than n frames, all of them are trimmed.
+//
+// Stack traces reused from panic values carrying their own are never trimmed.
+func SetStackSkip(n int) {
+	stackSkip.Store(int32(n))
+}
+
+var maxStackDepth atomic.Int32 // maximum number of stack frames to keep
+
+// SetMaxStackDepth configures the maximum number of frames kept in the stack
+// traces of recovered panics, to bound the size of reports from deeply
+// recursive code. Longer stack traces keep their innermost n frames followed
+// by a frame for the function safe.stackTruncated, indicating that the rest
+// were dropped. If n is zero or less, all frames are kept, which is the
+// default.
+func SetMaxStackDepth(n int) {
+	maxStackDepth.Store(int32(n))
+}
+
+// stackTruncated is never called. Its frame marks the end of a truncated stack
+// trace.
+func stackTruncated() {}
+
+// truncatedFrame returns the frame marking the end of a truncated stack trace.
+func truncatedFrame() errors.Frame {
+	// Frames hold return addresses, which are decremented when formatted.
+	return errors.Frame(reflect.ValueOf(stackTruncated).Pointer() + 1)
+}
+
+// goroutineID returns the ID of the calling goroutine, parsed from the header
+// of its stack trace, or zero if it cannot be determined.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+var noStack atomic.Bool // whether stack trace capture is disabled
+
+// SetCaptureStack configures whether a stack trace is captured when a panic is
+// recovered. It is enabled by default. Disabling it avoids the cost of
+// capturing the stack trace in code paths where panics are expected; the
+// resulting PanicError then has an empty stack trace and is formatted with %+v
+// as just its message.
+//
+// Panic values that already carry a pkg/errors stack trace keep it regardless.
+func SetCaptureStack(enabled bool) {
+	noStack.Store(!enabled)
+}
+
+// Recover converts a value returned by recover() into a safe.PanicError,
+// identical to the one returned by Do. It returns nil if recovered is nil.
+//
+// Recover must be called directly from the deferred function so that the stack
+// trace includes the site of the panic:
+//
+//	defer func() {
+//		if err := safe.Recover(recover()); err != nil {
+//			// handle err
+//		}
+//	}()
+func Recover(recovered interface{}) error {
+	if recovered == nil {
+		return nil
+	}
+	return panicError(recovered)
+}
+
+// RecoverInto recovers a panic and stores it in *errp as a safe.PanicError,
+// joined with errors.Join to any error already there. It does nothing if there
+// is no panic. It must be deferred directly, typically with a named result, so
+// that an error assigned before the panic is kept:
+//
+//	func fn() (err error) {
+//		defer safe.RecoverInto(&err)
+//		...
+//	}
+func RecoverInto(errp *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if p := panicError(recovered); *errp != nil {
+		*errp = stderrors.Join(*errp, p)
+	} else {
+		*errp = p
+	}
 }
 
 // Do executes fn. If a panic occurs, it will be recovered and returned as a
-// safe.PanicError.
+// safe.PanicError. Do itself does not allocate unless a panic occurs, so it is
+// suitable for hot code paths.
+//
+// If fn panics after assigning an error to a named result, or a function
+// deferred by fn panics after fn has returned an error, that error is local to
+// fn and is discarded by the runtime when the panic unwinds it, before Do can
+// observe it, so only the safe.PanicError is returned. To keep both, fn must
+// recover the panic itself with RecoverInto, deferred before any function that
+// may panic:
+//
+//	func fn() (err error) {
+//		defer safe.RecoverInto(&err)
+//		defer cleanup() // may panic
+//		...
+//	}
 func Do(fn func() error) (err error) {
+	if fn == nil {
+		return ErrNilFunc
+	}
 	defer func() {
 		if r := recover(); r != nil {
 			err = panicError(r)
@@ -52,28 +400,370 @@ func Do(fn func() error) (err error) {
 	return fn()
 }
 
+// Options configures how DoWith and GoWith handle a recovered panic.
+type Options struct {
+	// Interceptors are called in order with the recovered panic value before
+	// it is converted by Transform. If an interceptor panics, the panic is
+	// logged and the value it received is passed on unchanged.
+	Interceptors []Interceptor
+
+	// Transform converts the recovered panic value into the error returned by
+	// DoWith or reported by GoWith, instead of the default safe.PanicError.
+	// Returning nil swallows the panic. If nil, the default conversion is
+	// used.
+	Transform func(recovered interface{}) error
+}
+
+// An Interceptor observes a recovered panic value, like middleware. It passes
+// the value, or a replacement for it, on to next. Not calling next swallows
+// the panic.
+type Interceptor func(recovered interface{}, next func(recovered interface{}))
+
+// recovered converts a recovered panic value into an error according to opts,
+// returning nil if the panic is swallowed.
+func (opts Options) recovered(val interface{}) error {
+	val, ok := intercept(opts.Interceptors, val)
+	if ok && opts.Transform == nil {
+		return panicError(val)
+	}
+	countPanic()
+	if !ok {
+		return nil
+	}
+	return opts.Transform(val)
+}
+
+// intercept passes val through interceptors in order, returning the resulting
+// value and false if an interceptor swallowed it.
+func intercept(interceptors []Interceptor, val interface{}) (interface{}, bool) {
+	for _, fn := range interceptors {
+		var ok bool
+		if val, ok = callInterceptor(fn, val); !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// callInterceptor passes val to fn, returning the value fn passes to next and
+// whether it did so.
+func callInterceptor(fn Interceptor, val interface{}) (res interface{}, ok bool) {
+	// Catch panics in the interceptor.
+	defer func() {
+		if r := recover(); r != nil {
+			logf("panic in panic interceptor: %+v\noriginal: %v\n", panicError(r), val)
+			if !ok {
+				res, ok = val, true
+			}
+		}
+	}()
+	fn(val, func(recovered interface{}) {
+		res, ok = recovered, true
+	})
+	return res, ok
+}
+
+// DoWith is like Do, but handles a recovered panic according to opts.
+func DoWith(opts Options, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = opts.recovered(r)
+		}
+	}()
+	return fn()
+}
+
+// DoQuiet is like Do, but never captures a stack trace for a recovered panic,
+// regardless of SetCaptureStack. It is intended for code paths where panics are
+// expected and handled by the caller rather than reported.
+func DoQuiet(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			countPanic()
+			err = PanicError{pkgError: &stackError{msg: fmt.Sprintf("panic: %v", r)}, val: r, at: getClock().Now(), md: reportMetadata.Load()}
+		}
+	}()
+	return fn()
+}
+
 // DoWithResult executes fn. If a panic occurs, it will be recovered and
 // returned as a safe.PanicError.
 func DoWithResult(fn func() (interface{}, error)) (res interface{}, err error) {
+	return DoTyped(fn)
+}
+
+// DoTyped executes fn and returns its typed result. If a panic occurs, it will
+// be recovered and returned as a safe.PanicError along with the zero value of
+// T.
+func DoTyped[T any](fn func() (T, error)) (res T, err error) {
+	if fn == nil {
+		return res, ErrNilFunc
+	}
 	defer func() {
 		if r := recover(); r != nil {
-			err = panicError(r)
+			var zero T
+			res, err = zero, panicError(r)
+		}
+	}()
+	return fn()
+}
+
+// DoWithResult2 executes fn and returns its two typed results. If a panic
+// occurs, it will be recovered and returned as a safe.PanicError along with
+// the zero values of A and B.
+func DoWithResult2[A, B any](fn func() (A, B, error)) (a A, b B, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zeroA A
+			var zeroB B
+			a, b, err = zeroA, zeroB, panicError(r)
+		}
+	}()
+	return fn()
+}
+
+// DoWithResult3 executes fn and returns its three typed results. If a panic
+// occurs, it will be recovered and returned as a safe.PanicError along with
+// the zero values of A, B and C.
+func DoWithResult3[A, B, C any](fn func() (A, B, C, error)) (a A, b B, c C, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zeroA A
+			var zeroB B
+			var zeroC C
+			a, b, c, err = zeroA, zeroB, zeroC, panicError(r)
 		}
 	}()
 	return fn()
 }
 
+// DoWithContext executes fn, passing it ctx. If ctx is already canceled, fn is
+// not called and ctx.Err() is returned. If a panic occurs, it will be recovered
+// and returned as a safe.PanicError. It is also passed to the panic handler in
+// ctx, if set with ContextWithPanicHandler.
+func DoWithContext(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := Do(func() error {
+		return fn(withRecovered(ctx))
+	})
+	if p, ok := err.(PanicError); ok {
+		if fn := contextPanicHandler(ctx); fn != nil {
+			callPanicHandler(fn, p)
+		}
+	}
+	return err
+}
+
+// DoWithCleanup executes fn and then cleanup, even if fn panics. If a panic
+// occurs in fn, it will be recovered and returned as a safe.PanicError. If
+// cleanup panics, that panic is also recovered and joined with the error from
+// fn using errors.Join.
+func DoWithCleanup(cleanup func(), fn func() error) error {
+	err := Do(fn)
+	cerr := Do(func() error {
+		cleanup()
+		return nil
+	})
+	switch {
+	case cerr == nil:
+		return err
+	case err == nil:
+		return cerr
+	}
+	return stderrors.Join(err, cerr)
+}
+
+// DoAll executes each of fns in turn, recovering panics from each
+// independently, and returns all non-nil errors from them joined with
+// errors.Join. Unlike a Group, it does not stop at the first error. Each
+// recovered panic is a distinct safe.PanicError in the joined error.
+func DoAll(fns ...func() error) error {
+	var errs []error
+	for _, fn := range fns {
+		if err := Do(fn); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
 // Go executes fn in a background goroutine. If a panic occurs, it will be
-// recovered and passed to the global panic handler.
+// recovered and passed to the global panic handler. If fn is nil, ErrNilFunc
+// is passed to the global panic handler instead.
 func Go(fn func()) {
-	go func() {
+	if fn == nil {
+		reportPanic(errors.WithStack(ErrNilFunc))
+		return
+	}
+	goFrom(caller(), fn)
+}
+
+// GoWith is like Go, but handles a recovered panic according to opts before
+// passing it to the global panic handler. If the panic is swallowed by opts,
+// the handler is not called.
+func GoWith(opts Options, fn func()) {
+	pc := caller()
+	spawn(pc, func() {
 		defer func() {
 			if r := recover(); r != nil {
-				handlePanic(r)
+				err := opts.recovered(r)
+				if p, ok := err.(PanicError); ok {
+					p.spawnPC = pc
+					handlePanicError(p)
+				} else if err != nil {
+					reportPanic(err)
+				}
 			}
 		}()
 		fn()
+	})
+}
+
+// goFrom executes fn in a background goroutine spawned by the call at pc.
+func goFrom(pc uintptr, fn func()) {
+	spawn(pc, func() {
+		runFrom(pc, fn)
+	})
+}
+
+// spawn calls run in a new goroutine on behalf of the call at pc, tracking it
+// if enabled.
+func spawn(pc uintptr, run func()) {
+	if !tracking.Load() {
+		go run()
+		return
+	}
+	id := track(pc)
+	go func() {
+		defer untrack(id)
+		defer leaveRecovered(enterRecovered())
+		run()
+	}()
+}
+
+// runFrom executes fn on behalf of the call at pc, passing any panic to the
+// global panic handler.
+func runFrom(pc uintptr, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			handlePanic(r, pc)
+		}
 	}()
+	fn()
+}
+
+// caller returns the program counter of the call to the function calling
+// caller.
+func caller() uintptr {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	return pcs[0]
+}
+
+// GoCtx executes fn in a background goroutine, passing it ctx. If ctx is
+// already canceled, fn is not started. If a panic occurs, it will be recovered
+// and passed to the panic handler in ctx, if set with ContextWithPanicHandler,
+// or else to the global panic handler.
+//
+// Canceling ctx after fn has started does not stop it; fn is responsible for
+// observing ctx and returning early.
+func GoCtx(ctx context.Context, fn func(ctx context.Context)) {
+	if ctx.Err() != nil {
+		return
+	}
+	goContext(caller(), ctx, fn)
+}
+
+// goContext executes fn in a background goroutine spawned by the call at pc,
+// passing it ctx and passing any panic to the panic handler in ctx or the
+// global panic handler.
+func goContext(pc uintptr, ctx context.Context, fn func(ctx context.Context)) {
+	ctx = withRecovered(ctx)
+	spawn(pc, func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err := panicError(r)
+				err.spawnPC = pc
+				handlePanicErrorContext(ctx, err)
+			}
+		}()
+		fn(ctx)
+	})
+}
+
+// GoFrom executes fn in a background goroutine, passing it a context that
+// carries the values of ctx, such as trace spans, but is not canceled when ctx
+// is, as with context.WithoutCancel. This lets background work outlive the
+// request that started it while keeping its trace. If a panic occurs, it will
+// be recovered and passed to the panic handler in ctx, if set with
+// ContextWithPanicHandler, or else to the global panic handler.
+func GoFrom(ctx context.Context, fn func(ctx context.Context)) {
+	goContext(caller(), context.WithoutCancel(ctx), fn)
+}
+
+// GoHandle executes fn in a background goroutine, passing it a new context. If
+// a panic occurs, it will be recovered and passed to the global panic handler.
+//
+// The returned cancel function cancels the context passed to fn, and the
+// returned channel is closed once fn has returned, even if it panicked.
+func GoHandle(fn func(ctx context.Context)) (cancel context.CancelFunc, done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan struct{})
+	goFrom(caller(), func() {
+		defer close(ch)
+		defer cancel()
+		fn(ctx)
+	})
+	return cancel, ch
+}
+
+// GoE executes fn in a background goroutine. The returned channel receives the
+// error returned by fn and is then closed. If a panic occurs, it will be
+// recovered and sent on the channel as a safe.PanicError rather than passed to
+// the global panic handler.
+func GoE(fn func() error) <-chan error {
+	pc := caller()
+	ch := make(chan error, 1)
+	spawn(pc, func() {
+		defer close(ch)
+		err := Do(fn)
+		if p, ok := err.(PanicError); ok {
+			p.spawnPC = pc
+			err = p
+		}
+		ch <- err
+	})
+	return ch
+}
+
+// GoN executes fn in n background goroutines, passing each its index from 0 to
+// n-1. If a panic occurs in any of them, it will be recovered and passed to the
+// global panic handler independently.
+func GoN(n int, fn func(i int)) {
+	pc := caller()
+	for i := 0; i < n; i++ {
+		i := i
+		goFrom(pc, func() {
+			fn(i)
+		})
+	}
+}
+
+// GoNWait is like GoN, but blocks until all n calls have returned, then
+// returns the first non-nil error (if any) from them. Panics are recovered and
+// returned as a safe.PanicError rather than passed to the global panic
+// handler.
+func GoNWait(n int, fn func(i int) error) error {
+	var g Group
+	for i := 0; i < n; i++ {
+		i := i
+		g.Go(func() error {
+			return fn(i)
+		})
+	}
+	return g.Wait()
 }
 
 // A Group is a drop-in replacement for errgroup.Group, a collection of
@@ -82,8 +772,25 @@ func Go(fn func()) {
 //
 // A zero Group is valid and does not cancel on error.
 type Group struct {
-	g    *errgroup.Group
-	once sync.Once
+	g            *errgroup.Group
+	once         sync.Once
+	panicHandler atomic.Value // per-group panic handler
+
+	mu           sync.Mutex
+	err          error             // first error
+	collect      bool              // whether all errors are collected
+	errs         []error           // errors in submission order, if collecting
+	preferPanics bool              // whether Wait prefers panics over errors
+	panicErr     error             // first recovered panic
+	running      int               // number of functions that have not yet returned
+	done         chan struct{}     // closed when running drops to zero
+	onComplete   []func(err error) // callbacks to call from Wait
+	rate         *launchRate       // launch rate limit, if set
+	continueOn   bool              // whether panics are recorded instead of failing the group
+	panics       []*PanicError     // panics recorded with continueOn set
+	maxTasks     int               // limit on the number of submitted functions, if positive
+	submitted    int               // number of functions submitted
+	limit        int               // limit set with SetLimit, if positive
 }
 
 // GroupWithContext returns a new Group and an associated Context derived from
@@ -97,6 +804,35 @@ func GroupWithContext(ctx context.Context) (*Group, context.Context) {
 	return &Group{g: g}, ctx
 }
 
+// A GroupOption configures a Group created with NewGroup.
+type GroupOption func(g *Group)
+
+// WithLimit returns a GroupOption that limits the number of active goroutines
+// in the group. See Group.SetLimit.
+func WithLimit(n int) GroupOption {
+	return func(g *Group) {
+		g.SetLimit(n)
+	}
+}
+
+// WithPanicHandler returns a GroupOption that configures a handler for panics
+// in the group. See Group.SetPanicHandler.
+func WithPanicHandler(fn func(err error)) GroupOption {
+	return func(g *Group) {
+		g.SetPanicHandler(fn)
+	}
+}
+
+// NewGroup returns a new Group configured with the given options. Like a zero
+// Group, it does not cancel on error; NewGroup() is equivalent to new(Group).
+func NewGroup(opts ...GroupOption) *Group {
+	g := &Group{}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
 func (g *Group) init() {
 	g.once.Do(func() {
 		if g.g == nil {
@@ -105,45 +841,707 @@ func (g *Group) init() {
 	})
 }
 
-// Go calls the given function in a new goroutine.
+// Go calls the given function in a new goroutine. It blocks until the new
+// goroutine can be added without the number of active goroutines in the group
+// exceeding the configured limit.
 //
 // The first call to panic or return a non-nil error cancels the group; its
 // error will be returned by Wait.
 func (g *Group) Go(fn func() error) {
 	g.init()
-	g.g.Go(func() error {
-		return Do(fn)
+	if !g.admit() {
+		return
+	}
+	g.waitRate()
+	g.submit(g.task("", fn))
+}
+
+// GoNamed is like Go, but labels the function with name. If the function
+// panics, the name is recorded in the resulting safe.PanicError, which is
+// passed to the group's panic handler and returned by Wait. This identifies
+// which of several functions caused the group to be canceled.
+func (g *Group) GoNamed(name string, fn func() error) {
+	g.init()
+	if !g.admit() {
+		return
+	}
+	g.waitRate()
+	g.submit(g.task(name, fn))
+}
+
+// GoVoid is like Go for a function that does not return an error. If it
+// panics, the panic cancels the group and is returned by Wait, exactly as for
+// Go.
+func (g *Group) GoVoid(fn func()) {
+	if fn == nil {
+		g.Go(nil)
+		return
+	}
+	g.Go(func() error {
+		fn()
+		return nil
 	})
 }
 
+// TryGo calls the given function in a new goroutine only if the number of
+// active goroutines in the group is currently below the configured limit, and
+// the configured launch rate currently allows it.
+//
+// The return value reports whether the goroutine was started.
+func (g *Group) TryGo(fn func() error) bool {
+	g.init()
+	if !g.reserveTask() {
+		return false
+	}
+	if r := g.launchRate(); r != nil && !r.allow() {
+		g.releaseTask()
+		return false
+	}
+	if !g.g.TryGo(g.task("", fn)) {
+		g.finish()
+		g.releaseTask()
+		return false
+	}
+	return true
+}
+
+// SetPanicHandler configures a handler for any panics that occur in functions
+// passed to this group's Go or TryGo methods. The handler is called in addition
+// to the panic being returned from Wait. If unset, panics are only returned
+// from Wait.
+func (g *Group) SetPanicHandler(fn func(err error)) {
+	g.panicHandler.Store(fn)
+}
+
+// CollectAll configures the group to collect the errors of all function calls
+// from the Go method, rather than only the first. Wait then returns every error
+// and recovered panic joined with errors.Join, in submission order.
+//
+// The derived Context of a group created with GroupWithContext is still
+// canceled by the first error. CollectAll must be called before any calls to
+// Go.
+func (g *Group) CollectAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.collect = true
+}
+
+// PreferPanics configures whether Wait prefers recovered panics over ordinary
+// errors. By default, Wait returns whichever error occurred first. If prefer is
+// true and any function panicked, Wait instead returns the first recovered
+// panic, even if an ordinary error occurred before it.
+//
+// PreferPanics has no effect if CollectAll was called.
+func (g *Group) PreferPanics(prefer bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.preferPanics = prefer
+}
+
+// ContinueOnPanic configures whether a panic in a function passed to Go is
+// treated as non-fatal. If enabled, a recovered panic is still passed to the
+// group's panic handler, but it does not cancel the group's Context and is not
+// returned by Wait; only returned errors do. The recovered panics are instead
+// available from Panics.
+func (g *Group) ContinueOnPanic(enabled bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.continueOn = enabled
+}
+
+// Panics returns the panics recovered from functions passed to Go while
+// ContinueOnPanic was enabled, in the order they occurred.
+func (g *Group) Panics() []*PanicError {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]*PanicError(nil), g.panics...)
+}
+
+// task wraps fn for submission to the underlying errgroup, counting it as
+// running and reserving a slot for its error if the group is collecting all
+// errors. If the returned function is not submitted, finish must be called.
+func (g *Group) task(name string, fn func() error) func() error {
+	g.mu.Lock()
+	g.running++
+	collect, i := g.collect, len(g.errs)
+	if collect {
+		g.errs = append(g.errs, nil)
+	}
+	g.mu.Unlock()
+
+	return func() error {
+		defer g.finish()
+		if tracking.Load() {
+			defer leaveRecovered(enterRecovered())
+		}
+		defer leaveSlot(g, enterSlot(g))
+		err := g.do(name, fn)
+		if err == nil {
+			return nil
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if p, ok := err.(PanicError); ok && g.continueOn {
+			g.panics = append(g.panics, &p)
+			return nil
+		}
+		if g.err == nil {
+			g.err = err
+		}
+		if collect {
+			g.errs[i] = err
+		}
+		if _, ok := err.(PanicError); ok && g.panicErr == nil {
+			g.panicErr = err
+		}
+		return err
+	}
+}
+
+// finish records that a function counted by task has returned.
+func (g *Group) finish() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running--
+	if g.running == 0 && g.done != nil {
+		close(g.done)
+		g.done = nil
+	}
+}
+
+// Running returns the number of functions passed to Go or TryGo that have not
+// yet returned.
+func (g *Group) Running() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.running
+}
+
+// Done returns a channel that is closed once all functions passed to Go or
+// TryGo have returned. Unlike Wait, it does not block.
+//
+// If no functions are running, the returned channel is already closed. A
+// function submitted after the channel was closed is tracked by the channel
+// returned from the next call to Done.
+func (g *Group) Done() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.doneLocked()
+}
+
+// doneLocked is like Done, but requires mu to be held.
+func (g *Group) doneLocked() <-chan struct{} {
+	if g.done != nil {
+		return g.done
+	}
+	done := make(chan struct{})
+	if g.running == 0 {
+		close(done)
+	} else {
+		g.done = done
+	}
+	return done
+}
+
+// do executes fn, labeling any recovered panic with name and passing it to the
+// group's panic handler.
+func (g *Group) do(name string, fn func() error) error {
+	err := Do(fn)
+	if p, ok := err.(PanicError); ok {
+		p.name = name
+		err = p
+		if h, _ := g.panicHandler.Load().(func(err error)); h != nil {
+			callPanicHandler(h, err)
+		}
+	}
+	return err
+}
+
+// SetLimit limits the number of active goroutines in this group to at most n.
+// A negative value indicates no limit.
+//
+// Any subsequent call to the Go method will block until it can add an active
+// goroutine without exceeding the configured limit.
+//
+// The limit must not be modified while any goroutines in the group are active;
+// doing so panics.
+//
+// Nesting groups with limits can deadlock: if all of the active functions of
+// a group are blocked submitting to, or waiting on, another group that cannot
+// make progress until they return, the limit is never freed. See
+// SetDeadlockDetection.
+func (g *Group) SetLimit(n int) {
+	g.init()
+	g.g.SetLimit(n)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.limit = n
+}
+
+// SetRate limits the rate at which functions are launched to perSecond per
+// second. Consecutive calls to the Go method are spaced out evenly, blocking as
+// needed, without allowing bursts. A zero or negative rate removes the limit.
+//
+// SetRate may be combined with SetLimit: Go first waits for the rate limit and
+// then for the number of active goroutines to drop below the limit, so a
+// function may start later than the rate alone allows.
+func (g *Group) SetRate(perSecond float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if perSecond <= 0 {
+		g.rate = nil
+		return
+	}
+	g.rate = newLaunchRate(perSecond)
+}
+
+// ErrTooManyTasks is returned by Group.Wait if more functions were submitted
+// than allowed by SetMaxTasks.
+var ErrTooManyTasks = stderrors.New("safe: too many tasks")
+
+// SetMaxTasks limits the total number of functions that may be submitted to
+// the group to n. Unlike SetLimit, which bounds the number of functions running
+// at once, it bounds the number submitted over the group's lifetime. Once n
+// functions have been submitted, further calls to Go do nothing except record
+// ErrTooManyTasks as an error to be returned by Wait, and TryGo returns false.
+// A zero or negative n removes the limit.
+func (g *Group) SetMaxTasks(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.maxTasks = n
+}
+
+// admit counts a submitted function, recording ErrTooManyTasks and reporting
+// false if it exceeds the limit set with SetMaxTasks.
+func (g *Group) admit() bool {
+	if g.reserveTask() {
+		return true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err == nil {
+		g.err = ErrTooManyTasks
+	}
+	if g.collect {
+		g.errs = append(g.errs, ErrTooManyTasks)
+	}
+	return false
+}
+
+// reserveTask counts a submitted function, reporting false without counting
+// it if it exceeds the limit set with SetMaxTasks.
+func (g *Group) reserveTask() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.maxTasks > 0 && g.submitted >= g.maxTasks {
+		return false
+	}
+	g.submitted++
+	return true
+}
+
+// releaseTask uncounts a function counted by reserveTask that was not started.
+func (g *Group) releaseTask() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.submitted--
+}
+
+// launchRate returns the configured launch rate limit, or nil if unset.
+func (g *Group) launchRate() *launchRate {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.rate
+}
+
+// waitRate blocks until the configured launch rate allows another function to
+// be launched.
+func (g *Group) waitRate() {
+	if r := g.launchRate(); r != nil {
+		r.wait()
+	}
+}
+
 // Wait blocks until all function calls from the Go method have returned, then
-// returns the first non-nil error (if any) from them.
+// returns the first non-nil error (if any) from them, or all of them if
+// CollectAll was called. See PreferPanics for how panics are prioritized.
+//
+// Go may be called concurrently with Wait, e.g. by a producer goroutine; Wait
+// then also waits for the newly submitted functions. Functions submitted after
+// Wait has returned are not waited for, and in a group created with
+// GroupWithContext they receive an already canceled Context.
 func (g *Group) Wait() error {
 	g.init()
-	return g.g.Wait()
+	g.mu.Lock()
+	for g.running > 0 {
+		done := g.doneLocked()
+		g.mu.Unlock()
+		id := block(g, false)
+		<-done
+		unblock(id)
+		g.mu.Lock()
+	}
+	// Functions are counted as running before they are submitted to the
+	// errgroup, so while mu is held no submission can race with waiting on it.
+	g.g.Wait()
+	err := g.resultLocked()
+	fns := g.onComplete
+	g.onComplete = nil
+	g.mu.Unlock()
+
+	for _, fn := range fns {
+		if perr := Do(func() error { fn(err); return nil }); perr != nil {
+			reportPanic(perr)
+		}
+	}
+	return err
+}
+
+// Reset clears the errors and recovered panics recorded by the group once Wait
+// has returned, so that the group can be reused for a new batch of functions.
+// Its configuration, such as the limit, rate, panic handler and error policy,
+// is preserved. The derived Context of a group created with GroupWithContext is
+// not renewed, so such groups should not be reused.
+//
+// Reset panics if any function calls from the Go method are still running.
+func (g *Group) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.running > 0 {
+		panic("safe: Group.Reset called while functions are running")
+	}
+	g.err = nil
+	clear(g.errs)
+	g.errs = g.errs[:0]
+	g.panicErr = nil
+	g.panics = nil
+	g.onComplete = nil
+	g.submitted = 0
+}
+
+// WaitOrPanic is like Wait, but if the error Wait would return is or contains a
+// safe.PanicError, it panics again with the original panic value, preserving
+// its type, so that the panic propagates across the group boundary to an
+// enclosing safe.Do or recover. Ordinary errors are returned, not panicked.
+// Use PreferPanics to favor panics over errors that occurred before them.
+func (g *Group) WaitOrPanic() error {
+	err := g.Wait()
+	if p, ok := AsPanicError(err); ok {
+		panic(p.val)
+	}
+	return err
+}
+
+// OnComplete registers fn to be called with the error returned by Wait, once
+// all function calls from the Go method have returned. Callbacks are called in
+// registration order, each exactly once, even if Wait is called several times.
+// A panic in a callback is passed to the global panic handler and does not
+// prevent the remaining callbacks from running.
+func (g *Group) OnComplete(fn func(err error)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onComplete = append(g.onComplete, fn)
+}
+
+// result returns the error to be returned by Wait, based on the functions that
+// have returned so far.
+func (g *Group) result() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resultLocked()
+}
+
+// resultLocked is like result, but requires mu to be held.
+func (g *Group) resultLocked() error {
+	switch {
+	case g.collect:
+		return stderrors.Join(g.errs...)
+	case g.preferPanics && g.panicErr != nil:
+		return g.panicErr
+	}
+	return g.err
+}
+
+// WaitContext is like Wait, but returns ctx.Err() as soon as ctx is done, even
+// if some function calls from the Go method are still running.
+//
+// Returning early detaches any in-flight goroutines: they are not stopped and
+// continue running in the background. Wait may be called later to block until
+// they have all returned.
+func (g *Group) WaitContext(ctx context.Context) error {
+	done := g.waitAsync()
+	defer unblock(block(g, false))
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ErrWaitTimeout is returned by Group.WaitTimeout if the functions in the group
+// do not all return in time.
+var ErrWaitTimeout = stderrors.New("safe: timed out waiting for group")
+
+// WaitTimeout is like Wait, but returns after at most d. If some function calls
+// from the Go method are still running by then, it returns ErrWaitTimeout,
+// joined with the error that Wait would return based on the functions that
+// have already returned (if any).
+//
+// Like WaitContext, returning early detaches any in-flight goroutines: they
+// are not stopped and continue running in the background.
+func (g *Group) WaitTimeout(d time.Duration) error {
+	select {
+	case err := <-g.waitAsync():
+		return err
+	case <-after(d):
+		return stderrors.Join(ErrWaitTimeout, g.result())
+	}
 }
 
-var panicHandler atomic.Value // global panic handler
+// waitAsync calls Wait in a new goroutine and returns a channel receiving its
+// result.
+func (g *Group) waitAsync() <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- g.Wait()
+	}()
+	return done
+}
+
+var (
+	panicHandler   atomic.Value // global panic handlers, as []globalHandler
+	panicHandlerMu sync.Mutex   // serializes updates to panicHandler
+)
+
+// globalHandler is a global panic handler. Exactly one of fn, full or handled
+// is set.
+type globalHandler struct {
+	fn      func(err error)
+	full    func(err error, stack []byte)
+	handled func(err error) bool
+}
+
+// call passes err to the handler, along with stack if it accepts one.
+func (h globalHandler) call(err error, stack []byte) {
+	switch {
+	case h.full != nil:
+		callPanicHandler(func(err error) { h.full(err, stack) }, err)
+	case h.handled != nil:
+		callPanicHandler(func(err error) {
+			if !h.handled(err) {
+				callPanicHandler(nil, err)
+			}
+		}, err)
+	default:
+		callPanicHandler(h.fn, err)
+	}
+}
 
 // SetPanicHandler configures a global handler for any panics that occur in
 // background goroutines spawned by safe.Go. If unset, they'll instead be
 // written directly to the log.
+//
+// SetPanicHandler replaces any handlers previously registered with
+// AddPanicHandler, SetPanicHandlerFull or SetPanicHandlerE.
 func SetPanicHandler(fn func(err error)) {
-	panicHandler.Store(fn)
+	if fn == nil {
+		storePanicHandlers(nil)
+		return
+	}
+	storePanicHandlers([]globalHandler{{fn: fn}})
+}
+
+// SetPanicHandlerFull is like SetPanicHandler, but fn also receives the stack
+// of the panicking goroutine as formatted by runtime.Stack. The runtime stack
+// includes frames, such as those of inlined functions, that may be missing
+// from the error's stack trace.
+func SetPanicHandlerFull(fn func(err error, stack []byte)) {
+	if fn == nil {
+		storePanicHandlers(nil)
+		return
+	}
+	storePanicHandlers([]globalHandler{{full: fn}})
+}
+
+// SetPanicHandlerE is like SetPanicHandler, but fn reports whether it handled
+// the panic. If it returns false, the panic is also written to the log as if no
+// handler were set.
+func SetPanicHandlerE(fn func(err error) (handled bool)) {
+	if fn == nil {
+		storePanicHandlers(nil)
+		return
+	}
+	storePanicHandlers([]globalHandler{{handled: fn}})
+}
+
+// AddPanicHandler registers an additional global panic handler. All registered
+// handlers are called for each panic, in registration order. A panic in one
+// handler does not prevent the others from running.
+func AddPanicHandler(fn func(err error)) {
+	if fn == nil {
+		return
+	}
+	panicHandlerMu.Lock()
+	defer panicHandlerMu.Unlock()
+	old := loadPanicHandlers()
+	hs := make([]globalHandler, len(old), len(old)+1)
+	copy(hs, old)
+	panicHandler.Store(append(hs, globalHandler{fn: fn}))
+}
+
+// GetPanicHandler returns a function calling the global panic handlers
+// configured with SetPanicHandler, SetPanicHandlerFull, SetPanicHandlerE and
+// AddPanicHandler, or nil if none are set.
+func GetPanicHandler() func(err error) {
+	hs := loadPanicHandlers()
+	switch {
+	case len(hs) == 0:
+		return nil
+	case len(hs) == 1 && hs[0].fn != nil:
+		return hs[0].fn
+	}
+	return func(err error) {
+		callPanicHandlers(hs, err)
+	}
+}
+
+// ResetPanicHandler clears the global panic handlers so that panics are once
+// again written directly to the log.
+func ResetPanicHandler() {
+	SetPanicHandler(nil)
+}
+
+func storePanicHandlers(hs []globalHandler) {
+	storeHandlers(hs, nil)
+}
+
+// storeHandlers replaces the global panic handlers with hs, and the
+// asynchronous panic handler with a, closing the one it replaces.
+func storeHandlers(hs []globalHandler, a *asyncHandler) {
+	panicHandlerMu.Lock()
+	defer panicHandlerMu.Unlock()
+	panicHandler.Store(hs)
+	if old := asyncPanics.Swap(a); old != nil {
+		old.close()
+	}
 }
 
-func handlePanic(val interface{}) {
+func loadPanicHandlers() []globalHandler {
+	hs, _ := panicHandler.Load().([]globalHandler)
+	return hs
+}
+
+// HandlePanic converts a value returned by recover() into a safe.PanicError and
+// passes it to the global panic handler, exactly as safe.Go does. It returns
+// the resulting error, or nil if recovered is nil.
+//
+// Like Recover, it must be called directly from the deferred function.
+func HandlePanic(recovered interface{}) error {
+	if recovered == nil {
+		return nil
+	}
+	return handlePanic(recovered, 0)
+}
+
+// handlePanic passes the panic value to the global panic handlers and returns
+// the resulting error. spawnPC is the program counter of the call spawning the
+// panicking goroutine, or zero if unknown. handlePanic must be called from the
+// deferred function that recovered val so that the stack of the panicking
+// goroutine is intact.
+func handlePanic(val interface{}, spawnPC uintptr) error {
 	err := panicError(val)
-	fn, _ := panicHandler.Load().(func(err error))
+	err.spawnPC = spawnPC
+	return handlePanicError(err)
+}
+
+// handlePanicError is like handlePanic for an existing PanicError.
+func handlePanicError(err PanicError) error {
+	reportPanic(err)
+	checkFatal(err.val)
+	return err
+}
+
+// checkFatal panics with val again if the fatal filter matches it.
+func checkFatal(val interface{}) {
+	if fn, _ := fatalFilter.Load().(func(recovered interface{}) bool); fn != nil && fn(val) {
+		panic(val)
+	}
+}
+
+var fatalFilter atomic.Value // filter for panics that should not be recovered
+
+// SetFatalFilter configures a filter for panic values that should terminate the
+// program. Panics passed to the global panic handler, such as those in
+// goroutines spawned by safe.Go, are first reported as usual. Then, if fn
+// returns true for the panic value, it is panicked again, crashing the program.
+//
+// If unset or nil, all panics are recovered.
+func SetFatalFilter(fn func(recovered interface{}) bool) {
+	fatalFilter.Store(fn)
+}
+
+// reportPanic passes err to the handler registered for the type of its panic
+// value, if any, or else to the global panic handlers.
+func reportPanic(err error) {
+	if p, ok := err.(PanicError); ok {
+		if fn := typePanicHandler(p.val); fn != nil {
+			callPanicHandler(func(err error) { fn(p.val, err) }, err)
+			return
+		}
+	}
+	hs := loadPanicHandlers()
+	if len(hs) == 0 {
+		callPanicHandler(nil, err)
+		return
+	}
+	callPanicHandlers(hs, err)
+}
+
+// callPanicHandlers passes err to each of hs in order. The stack of the
+// calling goroutine is only captured if a handler accepts it.
+func callPanicHandlers(hs []globalHandler, err error) {
+	var stack []byte
+	for _, h := range hs {
+		if h.full != nil && stack == nil {
+			stack = debug.Stack()
+		}
+		h.call(err, stack)
+	}
+}
+
+var fallbackLogger atomic.Value // fallback logging function
+
+// SetFallbackLogger configures the function used to log panics when no panic
+// handler is set, as well as panics that occur within panic handlers. If unset
+// or nil, log.Printf is used.
+func SetFallbackLogger(fn func(format string, args ...interface{})) {
+	fallbackLogger.Store(fn)
+}
+
+// logf writes to the fallback logger.
+func logf(format string, args ...interface{}) {
+	fn, _ := fallbackLogger.Load().(func(format string, args ...interface{}))
+	if fn == nil {
+		fn = log.Printf
+	}
+	fn(format, args...)
+}
+
+// callPanicHandler passes err to fn, falling back to the log if fn is nil.
+func callPanicHandler(fn func(err error), err error) {
 	if fn == nil {
-		log.Printf("%+v\n", err)
+		if allowPanicLog(err.Error()) {
+			logf("%+v\n", err)
+		}
 		return
 	}
 
 	// Catch panics in the panic handler.
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("panic in panic handler: %+v\noriginal: %+v\n", panicError(r), err)
+			logf("panic in panic handler: %+v\noriginal: %+v\n", panicError(r), err)
 		}
 	}()
 	fn(err)
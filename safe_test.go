@@ -0,0 +1,994 @@
+package safe_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	safe "github.com/thanhps42/safe-go"
+)
+
+// handlePanics sets a global panic handler for the duration of the test,
+// returning a channel receiving the errors passed to it.
+func handlePanics(t *testing.T) <-chan error {
+	t.Helper()
+	errs := make(chan error, 100)
+	safe.SetPanicHandler(func(err error) {
+		errs <- err
+	})
+	t.Cleanup(safe.ResetPanicHandler)
+	return errs
+}
+
+// requirePanicError fails the test unless err is a safe.PanicError with the
+// given panic value.
+func requirePanicError(t *testing.T, err error, val interface{}) *safe.PanicError {
+	t.Helper()
+	p, ok := safe.AsPanicError(err)
+	if !ok {
+		t.Fatalf("got error %v, want a PanicError", err)
+	}
+	if p.Panic() != val {
+		t.Fatalf("got panic value %v, want %v", p.Panic(), val)
+	}
+	return p
+}
+
+type result struct {
+	N int
+	S string
+}
+
+func TestDoTyped(t *testing.T) {
+	res, err := safe.DoTyped(func() (result, error) {
+		return result{1, "a"}, nil
+	})
+	if err != nil || res != (result{1, "a"}) {
+		t.Errorf("got %v, %v, want {1 a}, nil", res, err)
+	}
+
+	errFailed := errors.New("failed")
+	res, err = safe.DoTyped(func() (result, error) {
+		return result{2, "b"}, errFailed
+	})
+	if err != errFailed || res != (result{2, "b"}) {
+		t.Errorf("got %v, %v, want {2 b}, %v", res, err, errFailed)
+	}
+}
+
+func TestDoTypedPanic(t *testing.T) {
+	res, err := safe.DoTyped(func() (result, error) {
+		panic("boom")
+	})
+	requirePanicError(t, err, "boom")
+	if res != (result{}) {
+		t.Errorf("got result %v, want the zero value", res)
+	}
+
+	ptr, err := safe.DoTyped(func() (*result, error) {
+		panic("boom")
+	})
+	requirePanicError(t, err, "boom")
+	if ptr != nil {
+		t.Errorf("got result %v, want nil", ptr)
+	}
+}
+
+func TestPanicErrorComparable(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T)
+		do    func(fn func() error) error
+	}{
+		{"Do", nil, safe.Do},
+		{"DoQuiet", nil, safe.DoQuiet},
+		{"NoStack", func(t *testing.T) {
+			safe.SetCaptureStack(false)
+			t.Cleanup(func() { safe.SetCaptureStack(true) })
+		}, safe.Do},
+		{"StackSkip", func(t *testing.T) {
+			safe.SetStackSkip(2)
+			t.Cleanup(func() { safe.SetStackSkip(0) })
+		}, safe.Do},
+		{"MaxStackDepth", func(t *testing.T) {
+			safe.SetMaxStackDepth(1)
+			t.Cleanup(func() { safe.SetMaxStackDepth(0) })
+		}, safe.Do},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			err := tt.do(func() error { panic("x") })
+			if !errors.Is(err, err) {
+				t.Error("errors.Is(err, err) = false, want true")
+			}
+			if !errors.Is(err, safe.ErrPanic) {
+				t.Error("errors.Is(err, ErrPanic) = false, want true")
+			}
+		})
+	}
+
+	t.Run("StackTracer", func(t *testing.T) {
+		err := safe.Do(func() error { panic(pkgerrors.New("x")) })
+		if !errors.Is(err, err) {
+			t.Error("errors.Is(err, err) = false, want true")
+		}
+	})
+}
+
+func TestDoPanicStackTrace(t *testing.T) {
+	err := safe.Do(func() error {
+		panic(pkgerrors.New("x"))
+	})
+	p, ok := safe.AsPanicError(err)
+	if !ok {
+		t.Fatalf("got error %v, want a PanicError", err)
+	}
+	stack := p.StackTrace()
+	if len(stack) == 0 {
+		t.Fatal("got an empty stack trace")
+	}
+	if fn := fmt.Sprintf("%n", stack[0]); !strings.HasPrefix(fn, "TestDoPanicStackTrace") {
+		t.Errorf("got innermost frame %s, want the panic site in TestDoPanicStackTrace", fn)
+	}
+	if s := p.StackString(); strings.Contains(s, "safe-go.panicError") || strings.Contains(s, "safe-go.Do.func") {
+		t.Errorf("stack trace contains the recover site in safe.Do:\n%s", s)
+	}
+}
+
+func TestGroupSetMaxTasks(t *testing.T) {
+	var g safe.Group
+	g.SetMaxTasks(2)
+	var calls atomic.Int32
+	for i := 0; i < 4; i++ {
+		g.Go(func() error {
+			calls.Add(1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != safe.ErrTooManyTasks {
+		t.Errorf("got error %v, want ErrTooManyTasks", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("got %d calls, want 2", n)
+	}
+}
+
+func TestGroupSetMaxTasksTryGo(t *testing.T) {
+	var g safe.Group
+	g.SetLimit(1)
+	g.SetMaxTasks(2)
+	release := make(chan struct{})
+	g.Go(func() error {
+		<-release
+		return nil
+	})
+	if g.TryGo(func() error { return nil }) {
+		t.Error("TryGo succeeded at the concurrency limit")
+	}
+	close(release)
+	g.Go(func() error { return nil })
+	if err := g.Wait(); err != nil {
+		t.Errorf("got error %v, want nil: a rejected TryGo counted towards the limit", err)
+	}
+
+	if g.TryGo(func() error { return nil }) {
+		t.Error("TryGo succeeded beyond the task limit")
+	}
+	if err := g.Wait(); err != nil {
+		t.Errorf("got error %v, want nil: TryGo recorded an error", err)
+	}
+}
+
+var errPartial = errors.New("partial")
+
+func TestRecoverInto(t *testing.T) {
+	fn := func() (err error) {
+		defer safe.RecoverInto(&err)
+		err = errPartial
+		panic("boom")
+	}
+	err := safe.Do(fn)
+	if !errors.Is(err, errPartial) {
+		t.Errorf("Do() = %v, want it to wrap %v", err, errPartial)
+	}
+	requirePanicError(t, err, "boom")
+}
+
+func TestRecoverIntoNoError(t *testing.T) {
+	var err error
+	func() {
+		defer safe.RecoverInto(&err)
+		panic("boom")
+	}()
+	if _, ok := err.(safe.PanicError); !ok {
+		t.Errorf("err = %#v, want a safe.PanicError", err)
+	}
+
+	err = errPartial
+	func() {
+		defer safe.RecoverInto(&err)
+	}()
+	if err != errPartial {
+		t.Errorf("err = %v without a panic, want %v", err, errPartial)
+	}
+}
+
+func TestDoDeferredPanic(t *testing.T) {
+	// Without RecoverInto, the error returned by fn is lost when a function
+	// it deferred panics.
+	err := safe.Do(func() error {
+		defer func() { panic("cleanup") }()
+		return errPartial
+	})
+	if errors.Is(err, errPartial) {
+		t.Errorf("Do() = %v, want the returned error to be discarded", err)
+	}
+	requirePanicError(t, err, "cleanup")
+
+	err = safe.Do(func() (err error) {
+		defer safe.RecoverInto(&err)
+		defer func() { panic("cleanup") }()
+		return errPartial
+	})
+	if !errors.Is(err, errPartial) {
+		t.Errorf("Do() = %v, want it to wrap %v", err, errPartial)
+	}
+	requirePanicError(t, err, "cleanup")
+}
+
+func TestGroupGoDuringWait(t *testing.T) {
+	const n = 100
+	var (
+		g         safe.Group
+		count     atomic.Int32
+		started   = make(chan struct{})
+		submitted = make(chan struct{})
+	)
+	go func() {
+		// The first function keeps the group running until all others are
+		// submitted, so Wait must wait for all of them.
+		g.Go(func() error {
+			<-submitted
+			count.Add(1)
+			return nil
+		})
+		close(started)
+		for i := 1; i < n; i++ {
+			g.Go(func() error {
+				count.Add(1)
+				return nil
+			})
+		}
+		close(submitted)
+	}()
+
+	<-started
+	if err := g.Wait(); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	if got := count.Load(); got != n {
+		t.Errorf("Wait returned after %d functions, want %d", got, n)
+	}
+}
+
+func TestGroupTryGo(t *testing.T) {
+	var g safe.Group
+	g.SetLimit(1)
+	release := make(chan struct{})
+	if !g.TryGo(func() error {
+		<-release
+		return nil
+	}) {
+		t.Fatal("TryGo() = false below the limit")
+	}
+	if g.TryGo(func() error { return nil }) {
+		t.Error("TryGo() = true at the limit")
+	}
+	close(release)
+	if err := g.Wait(); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+
+	if !g.TryGo(func() error { panic("boom") }) {
+		t.Fatal("TryGo() = false after the slot was freed")
+	}
+	requirePanicError(t, g.Wait(), "boom")
+}
+
+func TestGoCtx(t *testing.T) {
+	errs := handlePanics(t)
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	safe.GoCtx(ctx, func(ctx context.Context) {
+		panic(ctx.Value(key{}))
+	})
+	requirePanicError(t, <-errs, "value")
+}
+
+func TestGoCtxCanceled(t *testing.T) {
+	safe.TrackGoroutines(true)
+	t.Cleanup(func() { safe.TrackGoroutines(false) })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var called atomic.Bool
+	safe.GoCtx(ctx, func(context.Context) { called.Store(true) })
+	if err := safe.WaitAll(context.Background()); err != nil {
+		t.Fatalf("WaitAll() = %v", err)
+	}
+	if called.Load() {
+		t.Error("GoCtx called fn with a canceled context")
+	}
+}
+
+func TestAsPanicErrorWrapped(t *testing.T) {
+	err := safe.Do(func() error { panic("boom") })
+	for i := 0; i < 3; i++ {
+		err = fmt.Errorf("layer %d: %w", i, err)
+	}
+	if !safe.IsPanic(err) {
+		t.Error("IsPanic() = false for a wrapped PanicError")
+	}
+	requirePanicError(t, err, "boom")
+
+	plain := fmt.Errorf("layer: %w", errPartial)
+	if safe.IsPanic(plain) {
+		t.Error("IsPanic() = true for an error without a PanicError")
+	}
+	if p, ok := safe.AsPanicError(plain); ok || p != nil {
+		t.Errorf("AsPanicError() = %v, %v, want nil, false", p, ok)
+	}
+}
+
+func TestDoWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var called bool
+	err := safe.DoWithContext(ctx, func(context.Context) error {
+		called = true
+		return nil
+	})
+	if err != context.Canceled || called {
+		t.Errorf("DoWithContext() with a canceled context = %v, called fn: %v; want %v without calling fn", err, called, context.Canceled)
+	}
+
+	err = safe.DoWithContext(context.Background(), func(context.Context) error {
+		return errPartial
+	})
+	if err != errPartial {
+		t.Errorf("DoWithContext() = %v, want %v", err, errPartial)
+	}
+
+	var handled error
+	ctx = safe.ContextWithPanicHandler(context.Background(), func(err error) { handled = err })
+	err = safe.DoWithContext(ctx, func(context.Context) error { panic("boom") })
+	requirePanicError(t, err, "boom")
+	if handled != err {
+		t.Errorf("context panic handler got %v, want %v", handled, err)
+	}
+}
+
+func TestGroupWaitContext(t *testing.T) {
+	var g safe.Group
+	g.Go(func() error { panic("boom") })
+	requirePanicError(t, g.WaitContext(context.Background()), "boom")
+
+	release := make(chan struct{})
+	g.Go(func() error {
+		<-release
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := g.WaitContext(ctx); err != context.Canceled {
+		t.Errorf("WaitContext() with a canceled context = %v, want %v", err, context.Canceled)
+	}
+	close(release)
+	g.Wait()
+}
+
+func TestGroupPreferPanics(t *testing.T) {
+	for _, prefer := range []bool{false, true} {
+		t.Run(fmt.Sprint(prefer), func(t *testing.T) {
+			var g safe.Group
+			// A limit of one makes the error occur before the panic.
+			g.SetLimit(1)
+			g.PreferPanics(prefer)
+			g.Go(func() error { return errPartial })
+			g.Go(func() error { panic("boom") })
+			err := g.Wait()
+			if prefer {
+				requirePanicError(t, err, "boom")
+			} else if err != errPartial {
+				t.Errorf("Wait() = %v, want the first error %v", err, errPartial)
+			}
+		})
+	}
+}
+
+func TestGroupWaitTimeout(t *testing.T) {
+	var g safe.Group
+	g.Go(func() error { return errPartial })
+	if err := g.WaitTimeout(time.Minute); err != errPartial {
+		t.Errorf("WaitTimeout() = %v, want %v", err, errPartial)
+	}
+
+	release := make(chan struct{})
+	g.Go(func() error {
+		<-release
+		return nil
+	})
+	err := g.WaitTimeout(10 * time.Millisecond)
+	if !errors.Is(err, safe.ErrWaitTimeout) {
+		t.Errorf("WaitTimeout() = %v, want %v", err, safe.ErrWaitTimeout)
+	}
+	if !errors.Is(err, errPartial) {
+		t.Errorf("WaitTimeout() = %v, want it to include the completed error %v", err, errPartial)
+	}
+	close(release)
+	g.Wait()
+}
+
+func TestDoWithResult2(t *testing.T) {
+	r := &result{1, "a"}
+	p, n, err := safe.DoWithResult2(func() (*result, int, error) {
+		return r, 2, nil
+	})
+	if p != r || n != 2 || err != nil {
+		t.Errorf("got %v, %v, %v, want %v, 2, nil", p, n, err, r)
+	}
+
+	p, n, err = safe.DoWithResult2(func() (*result, int, error) {
+		panic("boom")
+	})
+	requirePanicError(t, err, "boom")
+	if p != nil || n != 0 {
+		t.Errorf("got %v, %v on panic, want zero values", p, n)
+	}
+}
+
+func TestDoWithResult3(t *testing.T) {
+	r := &result{1, "a"}
+	v, p, s, err := safe.DoWithResult3(func() (result, *result, []string, error) {
+		return result{2, "b"}, r, []string{"c"}, errPartial
+	})
+	if v != (result{2, "b"}) || p != r || len(s) != 1 || err != errPartial {
+		t.Errorf("got %v, %v, %v, %v, want {2 b}, %v, [c], %v", v, p, s, err, r, errPartial)
+	}
+
+	v, p, s, err = safe.DoWithResult3(func() (result, *result, []string, error) {
+		panic("boom")
+	})
+	requirePanicError(t, err, "boom")
+	if v != (result{}) || p != nil || s != nil {
+		t.Errorf("got %v, %v, %v on panic, want zero values", v, p, s)
+	}
+}
+
+func TestGroupOnComplete(t *testing.T) {
+	errs := handlePanics(t)
+	var g safe.Group
+	var order []int
+	var got error
+	g.OnComplete(func(err error) {
+		order = append(order, 1)
+		got = err
+	})
+	g.OnComplete(func(error) {
+		order = append(order, 2)
+		panic("callback")
+	})
+	g.OnComplete(func(error) { order = append(order, 3) })
+	for i := 0; i < 3; i++ {
+		g.Go(func() error { panic("boom") })
+	}
+
+	err := g.Wait()
+	g.Wait()
+	requirePanicError(t, err, "boom")
+	if got != err {
+		t.Errorf("callback got %v, want %v", got, err)
+	}
+	if fmt.Sprint(order) != "[1 2 3]" {
+		t.Errorf("callbacks called in order %v, want [1 2 3] once each", order)
+	}
+	requirePanicError(t, <-errs, "callback")
+}
+
+var errSentinel = errors.New("sentinel")
+
+// validationError is a typed error produced by the transform in TestDoWith.
+type validationError struct{ field string }
+
+func (e *validationError) Error() string { return "invalid " + e.field }
+
+func TestDoWith(t *testing.T) {
+	opts := safe.Options{
+		Transform: func(recovered interface{}) error {
+			switch recovered {
+			case errSentinel:
+				return &validationError{"name"}
+			case "ignored":
+				return nil
+			}
+			return safe.Recover(recovered)
+		},
+	}
+
+	var verr *validationError
+	if err := safe.DoWith(opts, func() error { panic(errSentinel) }); !errors.As(err, &verr) || verr.field != "name" {
+		t.Errorf("DoWith() = %v, want a *validationError", err)
+	}
+	if err := safe.DoWith(opts, func() error { panic("ignored") }); err != nil {
+		t.Errorf("DoWith() = %v for a swallowed panic, want nil", err)
+	}
+	requirePanicError(t, safe.DoWith(opts, func() error { panic("boom") }), "boom")
+	if err := safe.DoWith(opts, func() error { return errPartial }); err != errPartial {
+		t.Errorf("DoWith() = %v, want %v", err, errPartial)
+	}
+}
+
+func TestDoAll(t *testing.T) {
+	if err := safe.DoAll(func() error { return nil }); err != nil {
+		t.Errorf("DoAll() = %v, want nil", err)
+	}
+
+	calls := 0
+	err := safe.DoAll(
+		func() error { calls++; return nil },
+		func() error { calls++; return errPartial },
+		func() error { calls++; panic("a") },
+		func() error { calls++; panic("b") },
+	)
+	if calls != 4 {
+		t.Errorf("called %d functions, want 4", calls)
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("DoAll() = %v, want a joined error", err)
+	}
+	errs := joined.Unwrap()
+	if len(errs) != 3 || errs[0] != errPartial {
+		t.Fatalf("DoAll() = %v, want %v and two panics", errs, errPartial)
+	}
+	requirePanicError(t, errs[1], "a")
+	requirePanicError(t, errs[2], "b")
+}
+
+func TestGoE(t *testing.T) {
+	ch := safe.GoE(func() error { return errPartial })
+	if err := <-ch; err != errPartial {
+		t.Errorf("got %v, want %v", err, errPartial)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after the error")
+	}
+
+	p := requirePanicError(t, <-safe.GoE(func() error { panic("boom") }), "boom")
+	if site, ok := p.SpawnedAt(); !ok || !strings.Contains(site, "safe_test.go") {
+		t.Errorf("SpawnedAt() = %q, %v, want the call to GoE", site, ok)
+	}
+}
+
+func TestGroupSetRate(t *testing.T) {
+	clock := useFakeClock(t)
+	var g safe.Group
+	g.SetRate(10)
+	for i := 0; i < 4; i++ {
+		g.Go(func() error { return nil })
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	// The first launch is immediate and the others are spaced by 100ms.
+	if got := fmt.Sprint(clock.Waits()); got != "[100ms 100ms 100ms]" {
+		t.Errorf("waited %s between launches, want [100ms 100ms 100ms]", got)
+	}
+
+	// Launches spaced out by the caller do not wait.
+	clock.Advance(time.Second)
+	g.Go(func() error { return nil })
+	g.Wait()
+	if n := len(clock.Waits()); n != 3 {
+		t.Errorf("waited %d times, want 3", n)
+	}
+}
+
+func TestGroupContinueOnPanic(t *testing.T) {
+	g, ctx := safe.GroupWithContext(context.Background())
+	g.ContinueOnPanic(true)
+	// One at a time, so the completing functions run after the panics.
+	g.SetLimit(1)
+	var completed atomic.Int32
+	for i := 0; i < 6; i++ {
+		i := i
+		g.Go(func() error {
+			if i%2 == 0 {
+				panic(i)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			completed.Add(1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Errorf("Wait() = %v, want nil", err)
+	}
+	if n := completed.Load(); n != 3 {
+		t.Errorf("%d functions completed, want 3", n)
+	}
+	panics := g.Panics()
+	if len(panics) != 3 {
+		t.Fatalf("Panics() returned %d panics, want 3", len(panics))
+	}
+	for i, p := range panics {
+		if p.Panic() != 2*i {
+			t.Errorf("panic %d has value %v, want %d", i, p.Panic(), 2*i)
+		}
+	}
+}
+
+func TestPanicValueAs(t *testing.T) {
+	if v, ok := safe.PanicValueAs[string](safe.Do(func() error { panic("boom") })); !ok || v != "boom" {
+		t.Errorf("PanicValueAs[string]() = %q, %v, want boom, true", v, ok)
+	}
+	if v, ok := safe.PanicValueAs[int](safe.Do(func() error { panic("boom") })); ok || v != 0 {
+		t.Errorf("PanicValueAs[int]() = %v, %v for a string, want 0, false", v, ok)
+	}
+
+	r := &result{1, "a"}
+	err := fmt.Errorf("wrapped: %w", safe.Do(func() error { panic(r) }))
+	if v, ok := safe.PanicValueAs[*result](err); !ok || v != r {
+		t.Errorf("PanicValueAs[*result]() = %v, %v, want %v, true", v, ok, r)
+	}
+	if v, ok := safe.PanicValueAs[error](safe.Do(func() error { panic(errPartial) })); !ok || v != errPartial {
+		t.Errorf("PanicValueAs[error]() = %v, %v, want %v, true", v, ok, errPartial)
+	}
+	if v, ok := safe.PanicValueAs[fmt.Stringer](safe.Do(func() error { panic(time.Second) })); !ok || v != time.Second {
+		t.Errorf("PanicValueAs[fmt.Stringer]() = %v, %v, want 1s, true", v, ok)
+	}
+	if _, ok := safe.PanicValueAs[string](errPartial); ok {
+		t.Error("PanicValueAs() = true for an error without a PanicError")
+	}
+}
+
+func TestGroupWaitOrPanic(t *testing.T) {
+	var g safe.Group
+	g.Go(func() error { return errPartial })
+	if err := g.WaitOrPanic(); err != errPartial {
+		t.Errorf("WaitOrPanic() = %v, want %v", err, errPartial)
+	}
+
+	r := &result{1, "a"}
+	var h safe.Group
+	h.Go(func() error { panic(r) })
+	err := safe.Do(func() error { return h.WaitOrPanic() })
+	if v, ok := safe.PanicValueAs[*result](err); !ok || v != r {
+		t.Errorf("re-panicked with %v, want the original value %v", err, r)
+	}
+}
+
+func TestNilFunc(t *testing.T) {
+	if err := safe.Do(nil); err != safe.ErrNilFunc {
+		t.Errorf("Do(nil) = %v, want ErrNilFunc", err)
+	}
+	if _, err := safe.DoTyped[int](nil); err != safe.ErrNilFunc {
+		t.Errorf("DoTyped(nil) = %v, want ErrNilFunc", err)
+	}
+	if _, err := safe.DoWithResult(nil); err != safe.ErrNilFunc {
+		t.Errorf("DoWithResult(nil) = %v, want ErrNilFunc", err)
+	}
+
+	errs := handlePanics(t)
+	safe.Go(nil)
+	if err := <-errs; !errors.Is(err, safe.ErrNilFunc) {
+		t.Errorf("Go(nil) reported %v, want ErrNilFunc", err)
+	}
+
+	var g safe.Group
+	g.Go(nil)
+	if err := g.Wait(); !errors.Is(err, safe.ErrNilFunc) {
+		t.Errorf("Group.Go(nil) then Wait() = %v, want ErrNilFunc", err)
+	}
+	var h safe.Group
+	h.GoVoid(nil)
+	if err := h.Wait(); !errors.Is(err, safe.ErrNilFunc) {
+		t.Errorf("Group.GoVoid(nil) then Wait() = %v, want ErrNilFunc", err)
+	}
+}
+
+func TestPanicErrorFormat(t *testing.T) {
+	clock := useFakeClock(t)
+	var g safe.Group
+	g.GoNamed("task", func() error { panic("boom\nline") })
+	err := g.Wait()
+	p := requirePanicError(t, err, "boom\nline")
+	*p = p.WithField("id", 7)
+
+	for _, verb := range []string{"%v", "%s"} {
+		if got := fmt.Sprintf(verb, *p); got != "panic: boom\nline" {
+			t.Errorf("%s = %q, want the message only", verb, got)
+		}
+	}
+	if got := fmt.Sprintf("%q", *p); got != `"panic: boom\nline"` {
+		t.Errorf("%%q = %s, want the quoted message", got)
+	}
+
+	got := fmt.Sprintf("%+v", *p)
+	lines := strings.Split(got, "\n")
+	if strings.Join(lines[:2], "\n") != "panic: boom\nline" {
+		t.Errorf("%%+v starts with %q, want the message", lines[0])
+	}
+	for _, want := range []string{
+		"\ngithub.com/thanhps42/safe-go_test.TestPanicErrorFormat",
+		"\ntime: " + clock.Now().Format(time.RFC3339Nano),
+		"\ntask: task",
+		"\nfields: id=7",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%%+v does not contain %q:\n%s", want, got)
+		}
+	}
+
+	got = fmt.Sprintf("%+v", <-safe.GoE(func() error { panic("boom") }))
+	if !strings.Contains(got, "\nspawned at: ") {
+		t.Errorf("%%+v does not contain the spawn site:\n%s", got)
+	}
+}
+
+func TestToPlainError(t *testing.T) {
+	err := fmt.Errorf("job: %w", safe.Do(func() error { panic("boom") }))
+	plain := safe.ToPlainError(err)
+	if safe.IsPanic(plain) || errors.Unwrap(plain) != nil {
+		t.Errorf("ToPlainError() = %#v, want a plain error", plain)
+	}
+	msg, stack, _ := strings.Cut(plain.Error(), "\n")
+	if msg != "job: panic: boom" {
+		t.Errorf("got message %q, want %q", msg, "job: panic: boom")
+	}
+	if !strings.Contains(stack, "safe-go_test.TestToPlainError") {
+		t.Errorf("got stack trace %q, want the panic site", stack)
+	}
+	if got := fmt.Sprintf("%+v", plain); got != plain.Error() {
+		t.Errorf("%%+v = %q, want the same as Error()", got)
+	}
+
+	if got := safe.ToPlainError(errPartial); got != errPartial {
+		t.Errorf("ToPlainError() = %v, want %v unchanged", got, errPartial)
+	}
+	if got := safe.ToPlainError(nil); got != nil {
+		t.Errorf("ToPlainError(nil) = %v, want nil", got)
+	}
+}
+
+func TestGroupGoVoid(t *testing.T) {
+	var g safe.Group
+	var calls atomic.Int32
+	g.GoVoid(func() { calls.Add(1) })
+	if err := g.Wait(); err != nil || calls.Load() != 1 {
+		t.Errorf("Wait() = %v after %d calls, want nil after 1", err, calls.Load())
+	}
+
+	g.GoVoid(func() { panic("boom") })
+	requirePanicError(t, g.Wait(), "boom")
+}
+
+func TestGroupReset(t *testing.T) {
+	var g safe.Group
+	g.CollectAll()
+	g.SetMaxTasks(2)
+	for batch := 0; batch < 3; batch++ {
+		g.Go(func() error { return errPartial })
+		g.Go(func() error { panic(batch) })
+		err := g.Wait()
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !ok || len(joined.Unwrap()) != 2 {
+			t.Fatalf("batch %d: Wait() = %v, want its two errors only", batch, err)
+		}
+		if v, ok := safe.PanicValueAs[int](err); !ok || v != batch {
+			t.Errorf("batch %d: got panic %v, want %d", batch, v, batch)
+		}
+		g.Reset()
+		if err := g.Wait(); err != nil {
+			t.Errorf("batch %d: Wait() after Reset = %v, want nil", batch, err)
+		}
+	}
+}
+
+func TestDoWithCleanup(t *testing.T) {
+	tests := []struct {
+		name              string
+		fn                func() error
+		cleanup           func()
+		fnVal, cleanupVal interface{} // expected panic values, if any
+	}{
+		{"fn", func() error { panic("fn") }, func() {}, "fn", nil},
+		{"cleanup", func() error { return nil }, func() { panic("cleanup") }, nil, "cleanup"},
+		{"both", func() error { panic("fn") }, func() { panic("cleanup") }, "fn", "cleanup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleaned := false
+			err := safe.DoWithCleanup(func() {
+				cleaned = true
+				tt.cleanup()
+			}, tt.fn)
+			if !cleaned {
+				t.Error("cleanup not called")
+			}
+
+			var vals []interface{}
+			errs := []error{err}
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				errs = joined.Unwrap()
+			}
+			for _, err := range errs {
+				p, ok := safe.AsPanicError(err)
+				if !ok {
+					t.Fatalf("got error %v, want a PanicError", err)
+				}
+				vals = append(vals, p.Panic())
+			}
+			var want []interface{}
+			for _, v := range []interface{}{tt.fnVal, tt.cleanupVal} {
+				if v != nil {
+					want = append(want, v)
+				}
+			}
+			if fmt.Sprint(vals) != fmt.Sprint(want) {
+				t.Errorf("got panics %v, want %v", vals, want)
+			}
+		})
+	}
+}
+
+func recurse(n int) {
+	if n == 0 {
+		panic("deep")
+	}
+	recurse(n - 1)
+}
+
+func TestSetMaxStackDepth(t *testing.T) {
+	const depth = 5
+	safe.SetMaxStackDepth(depth)
+	t.Cleanup(func() { safe.SetMaxStackDepth(0) })
+
+	p := requirePanicError(t, safe.Do(func() error {
+		recurse(100)
+		return nil
+	}), "deep")
+	st := p.StackTrace()
+	if len(st) != depth+1 {
+		t.Fatalf("got %d frames, want %d and the truncation marker:\n%s", len(st), depth, p.StackString())
+	}
+	if fn := fmt.Sprintf("%n", st[depth]); fn != "stackTruncated" {
+		t.Errorf("got last frame %s, want stackTruncated", fn)
+	}
+
+	// Shorter stack traces are kept whole.
+	safe.SetMaxStackDepth(1000)
+	p = requirePanicError(t, safe.Do(func() error { panic("shallow") }), "shallow")
+	if fn := fmt.Sprintf("%n", p.StackTrace()[len(p.StackTrace())-1]); fn == "stackTruncated" {
+		t.Error("stack trace truncated below the maximum depth")
+	}
+}
+
+func TestAddPanicHandler(t *testing.T) {
+	logs := make(chan string, 10)
+	safe.SetFallbackLogger(func(format string, args ...interface{}) {
+		logs <- fmt.Sprintf(format, args...)
+	})
+	t.Cleanup(func() { safe.SetFallbackLogger(nil) })
+	t.Cleanup(safe.ResetPanicHandler)
+
+	var calls []string
+	safe.SetPanicHandler(func(error) { calls = append(calls, "first") })
+	safe.AddPanicHandler(func(error) {
+		calls = append(calls, "second")
+		panic("handler")
+	})
+	safe.AddPanicHandler(func(err error) {
+		calls = append(calls, "third")
+		requirePanicError(t, err, "boom")
+	})
+
+	panicAndHandle("boom")
+	if got := strings.Join(calls, ","); got != "first,second,third" {
+		t.Errorf("handlers called as %s, want first,second,third", got)
+	}
+	// The panic in the second handler is logged along with the original one.
+	select {
+	case msg := <-logs:
+		if !strings.HasPrefix(msg, "panic in panic handler: panic: handler") || !strings.Contains(msg, "original: panic: boom") {
+			t.Errorf("got log %q, want the handler panic", msg)
+		}
+	default:
+		t.Error("panic in handler not logged")
+	}
+}
+
+func TestSetPanicHandlerFull(t *testing.T) {
+	stacks := make(chan []byte, 1)
+	safe.SetPanicHandlerFull(func(err error, stack []byte) {
+		requirePanicError(t, err, "boom")
+		stacks <- stack
+	})
+	t.Cleanup(safe.ResetPanicHandler)
+
+	panicAndHandle("boom")
+	stack := string(<-stacks)
+	if stack == "" {
+		t.Fatal("got an empty stack")
+	}
+	// The frame below the runtime's panic call is the panicking function.
+	_, after, ok := strings.Cut(stack, "\npanic(")
+	if !ok || !strings.Contains(after, "_test.panicAndHandle(") {
+		t.Errorf("stack does not point at the panicking function:\n%s", stack)
+	}
+}
+
+func TestSetPanicHandlerE(t *testing.T) {
+	logs := make(chan string, 10)
+	safe.SetFallbackLogger(func(format string, args ...interface{}) {
+		logs <- fmt.Sprintf(format, args...)
+	})
+	t.Cleanup(func() { safe.SetFallbackLogger(nil) })
+	safe.SetPanicHandlerE(func(err error) bool {
+		p, _ := safe.AsPanicError(err)
+		return p.Panic() == "handled"
+	})
+	t.Cleanup(safe.ResetPanicHandler)
+
+	panicAndHandle("handled")
+	select {
+	case msg := <-logs:
+		t.Errorf("got log %q for a handled panic, want none", msg)
+	default:
+	}
+
+	// An unhandled panic falls through to the default logger.
+	panicAndHandle("unhandled")
+	select {
+	case msg := <-logs:
+		if !strings.HasPrefix(msg, "panic: unhandled") {
+			t.Errorf("got log %q, want the unhandled panic", msg)
+		}
+	default:
+		t.Error("unhandled panic not logged")
+	}
+}
+
+func TestSetFatalFilter(t *testing.T) {
+	errs := handlePanics(t)
+	safe.SetFatalFilter(func(recovered interface{}) bool {
+		return recovered == "fatal"
+	})
+	t.Cleanup(func() { safe.SetFatalFilter(nil) })
+
+	// A filtered panic is reported, then panicked again.
+	func() {
+		defer func() {
+			if r := recover(); r != "fatal" {
+				t.Errorf("got panic %v, want fatal", r)
+			}
+		}()
+		panicAndHandle("fatal")
+		t.Error("filtered panic recovered")
+	}()
+	requirePanicError(t, <-errs, "fatal")
+
+	// Other panics are still recovered.
+	panicAndHandle("boom")
+	requirePanicError(t, <-errs, "boom")
+}
@@ -0,0 +1,48 @@
+// Package safegrpc provides gRPC server interceptors for recovering panics
+// using package safe.
+package safegrpc
+
+import (
+	"context"
+
+	safe "github.com/thanhps42/safe-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errInternal is returned to clients in place of a recovered panic. It never
+// includes details of the panic.
+var errInternal = status.Error(codes.Internal, "internal error")
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor that recovers
+// panics from handlers. A recovered panic is passed to the global safe panic
+// handler as a safe.PanicError and a codes.Internal error is returned to the
+// client.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				safe.HandlePanic(r)
+				resp, err = nil, errInternal
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor that recovers
+// panics from handlers. A recovered panic is passed to the global safe panic
+// handler as a safe.PanicError and a codes.Internal error is returned to the
+// client.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				safe.HandlePanic(r)
+				err = errInternal
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,77 @@
+package safegrpc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+	"github.com/thanhps42/safe-go/safegrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// handlePanics installs a global panic handler for the duration of the test,
+// returning a channel receiving the panics passed to it.
+func handlePanics(t *testing.T) <-chan error {
+	errs := make(chan error, 1)
+	safe.SetPanicHandler(func(err error) { errs <- err })
+	t.Cleanup(safe.ResetPanicHandler)
+	return errs
+}
+
+// checkInternal fails the test unless err is a codes.Internal status that
+// reveals nothing about the panic.
+func checkInternal(t *testing.T, err error) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.Internal {
+		t.Fatalf("got error %v, want a %v status", err, codes.Internal)
+	}
+	if msg := st.Message(); strings.Contains(msg, "secret") || strings.Contains(msg, ".go:") {
+		t.Errorf("status message %q leaks the panic", msg)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	errs := handlePanics(t)
+	intercept := safegrpc.UnaryServerInterceptor()
+
+	resp, err := intercept(context.Background(), "req", &grpc.UnaryServerInfo{FullMethod: "/test/Unary"},
+		func(context.Context, interface{}) (interface{}, error) {
+			panic("secret")
+		})
+	if resp != nil {
+		t.Errorf("got response %v, want nil", resp)
+	}
+	checkInternal(t, err)
+	if v, ok := safe.PanicValueAs[string](<-errs); !ok || v != "secret" {
+		t.Errorf("panic handler got panic value %q, want %q", v, "secret")
+	}
+}
+
+func TestUnaryServerInterceptorNoPanic(t *testing.T) {
+	intercept := safegrpc.UnaryServerInterceptor()
+	resp, err := intercept(context.Background(), "req", &grpc.UnaryServerInfo{FullMethod: "/test/Unary"},
+		func(_ context.Context, req interface{}) (interface{}, error) {
+			return req, nil
+		})
+	if resp != "req" || err != nil {
+		t.Errorf("got (%v, %v), want (req, nil)", resp, err)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	errs := handlePanics(t)
+	intercept := safegrpc.StreamServerInterceptor()
+
+	err := intercept(nil, nil, &grpc.StreamServerInfo{FullMethod: "/test/Stream"},
+		func(interface{}, grpc.ServerStream) error {
+			panic("secret")
+		})
+	checkInternal(t, err)
+	if v, ok := safe.PanicValueAs[string](<-errs); !ok || v != "secret" {
+		t.Errorf("panic handler got panic value %q, want %q", v, "secret")
+	}
+}
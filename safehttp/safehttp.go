@@ -0,0 +1,81 @@
+// Package safehttp provides net/http middleware for recovering panics using
+// package safe.
+package safehttp
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+// Handler returns an http.Handler that serves requests using next, recovering
+// any panics. A recovered panic is passed to the global safe panic handler as
+// a safe.PanicError and a 500 Internal Server Error response is written, or
+// the status chosen by the mapper set with SetStatusMapper.
+//
+// Panics with http.ErrAbortHandler are not recovered, so that net/http can
+// abort the response as documented.
+func Handler(next http.Handler) http.Handler {
+	return recoverer(next, func(_ *http.Request, rec interface{}) error {
+		return safe.HandlePanic(rec)
+	})
+}
+
+// HandlerWithReporter returns middleware that behaves like Handler, except
+// that recovered panics are passed to reporter along with the request being
+// served, instead of to the global safe panic handler. This allows the report
+// to include details such as the method, path and any values carried by the
+// request's context.
+//
+// The response body never includes details of the panic.
+func HandlerWithReporter(reporter func(r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return recoverer(next, func(r *http.Request, rec interface{}) error {
+			err := safe.Recover(rec)
+			reporter(r, err)
+			return err
+		})
+	}
+}
+
+var statusMapper atomic.Value // maps recovered panics to response statuses
+
+// SetStatusMapper configures a function choosing the status code of the
+// response written by Handler and HandlerWithReporter when a panic is
+// recovered. It is passed the resulting safe.PanicError, so it can inspect the
+// panic value, e.g. to map a validation panic to 400 Bad Request. If fn is nil
+// or returns a status that is not a valid error status (400 to 599), 500
+// Internal Server Error is used.
+func SetStatusMapper(fn func(err error) int) {
+	statusMapper.Store(fn)
+}
+
+// status returns the response status for the recovered panic err.
+func status(err error) int {
+	if fn, _ := statusMapper.Load().(func(err error) int); fn != nil {
+		if code := fn(err); code >= 400 && code <= 599 {
+			return code
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// recoverer returns an http.Handler that serves requests using next, passing
+// any recovered panic to report and writing an error response.
+func recoverer(next http.Handler, report func(r *http.Request, rec interface{}) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			code := status(report(r, rec))
+			http.Error(w, http.StatusText(code), code)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
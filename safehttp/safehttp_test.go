@@ -0,0 +1,76 @@
+package safehttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+	"github.com/thanhps42/safe-go/safehttp"
+)
+
+func TestHandler(t *testing.T) {
+	errs := make(chan error, 1)
+	safe.SetPanicHandler(func(err error) { errs <- err })
+	t.Cleanup(safe.ResetPanicHandler)
+
+	calls := 0
+	h := safehttp.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		calls++
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+	if v, ok := safe.PanicValueAs[string](<-errs); !ok || v != "boom" {
+		t.Errorf("panic handler got panic value %q, want %q", v, "boom")
+	}
+}
+
+func TestHandlerAbort(t *testing.T) {
+	h := safehttp.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Errorf("got panic %v, want http.ErrAbortHandler", r)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestSetStatusMapper(t *testing.T) {
+	safe.SetPanicHandler(func(error) {})
+	t.Cleanup(safe.ResetPanicHandler)
+	safehttp.SetStatusMapper(func(err error) int {
+		switch v, _ := safe.PanicValueAs[string](err); v {
+		case "invalid":
+			return http.StatusBadRequest
+		case "bogus":
+			return http.StatusOK // not an error status
+		}
+		return 0
+	})
+	t.Cleanup(func() { safehttp.SetStatusMapper(nil) })
+
+	for val, want := range map[string]int{
+		"invalid": http.StatusBadRequest,
+		"bogus":   http.StatusInternalServerError,
+		"other":   http.StatusInternalServerError,
+	} {
+		h := safehttp.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic(val)
+		}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != want {
+			t.Errorf("panic %q: got status %d, want %d", val, rec.Code, want)
+		}
+	}
+}
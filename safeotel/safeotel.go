@@ -0,0 +1,53 @@
+// Package safeotel records panics recovered using package safe on
+// OpenTelemetry spans.
+package safeotel
+
+import (
+	"context"
+	"fmt"
+
+	safe "github.com/thanhps42/safe-go"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RecordPanic records err as an exception event on the span in ctx, and sets
+// the span's status to error. If err is a safe.PanicError, the event's
+// exception.type is the type of the panic value and its exception.stacktrace
+// is the stack trace of the panic.
+//
+// It does nothing if err is nil or ctx has no recording span.
+func RecordPanic(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err == nil || !span.IsRecording() {
+		return
+	}
+
+	typ := fmt.Sprintf("%T", err)
+	var stack string
+	if p, ok := safe.AsPanicError(err); ok {
+		typ = fmt.Sprintf("%T", p.Panic())
+		stack = p.StackString()
+	}
+	span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(
+		semconv.ExceptionType(typ),
+		semconv.ExceptionMessage(err.Error()),
+		semconv.ExceptionStacktrace(stack),
+	))
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Go executes fn in a background goroutine, passing it ctx. If a panic occurs,
+// it will be recovered, passed to the global safe panic handler, and recorded
+// on the span in ctx.
+func Go(ctx context.Context, fn func(ctx context.Context)) {
+	go func() {
+		defer func() {
+			if err := safe.HandlePanic(recover()); err != nil {
+				RecordPanic(ctx, err)
+			}
+		}()
+		fn(ctx)
+	}()
+}
@@ -0,0 +1,91 @@
+package safeotel_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+	"github.com/thanhps42/safe-go/safeotel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingSpan is a trace.Span recording its events and status.
+type recordingSpan struct {
+	trace.Span // non-recording span for the remaining methods
+
+	mu     sync.Mutex
+	events map[string][]attribute.KeyValue
+	status codes.Code
+	done   chan struct{}
+}
+
+func newRecordingSpan() *recordingSpan {
+	return &recordingSpan{
+		Span:   trace.SpanFromContext(context.Background()),
+		events: make(map[string][]attribute.KeyValue),
+		done:   make(chan struct{}),
+	}
+}
+
+func (s *recordingSpan) IsRecording() bool { return true }
+
+func (s *recordingSpan) AddEvent(name string, opts ...trace.EventOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg := trace.NewEventConfig(opts...)
+	s.events[name] = cfg.Attributes()
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+	close(s.done)
+}
+
+// attr returns the value of the attribute with the given key of the named
+// event.
+func (s *recordingSpan) attr(event string, key attribute.Key) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, kv := range s.events[event] {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestGo(t *testing.T) {
+	safe.SetPanicHandler(func(error) {})
+	t.Cleanup(safe.ResetPanicHandler)
+
+	span := newRecordingSpan()
+	ctx := trace.ContextWithSpan(context.Background(), span)
+	safeotel.Go(ctx, func(context.Context) {
+		panic(42)
+	})
+	<-span.done
+
+	if span.status != codes.Error {
+		t.Errorf("got status %v, want %v", span.status, codes.Error)
+	}
+	if got := span.attr("exception", "exception.type"); got != "int" {
+		t.Errorf("got exception.type %q, want %q", got, "int")
+	}
+	if got := span.attr("exception", "exception.message"); got != "panic: 42" {
+		t.Errorf("got exception.message %q, want %q", got, "panic: 42")
+	}
+	if got := span.attr("exception", "exception.stacktrace"); !strings.Contains(got, "safeotel_test.TestGo") {
+		t.Errorf("got exception.stacktrace %q, want the panic site", got)
+	}
+}
+
+func TestRecordPanicNotRecording(t *testing.T) {
+	// Must not panic without a span in the context.
+	safeotel.RecordPanic(context.Background(), safe.Do(func() error { panic("boom") }))
+}
@@ -0,0 +1,58 @@
+// Package safesentry reports panics recovered using package safe to Sentry.
+package safesentry
+
+import (
+	"fmt"
+
+	"github.com/getsentry/sentry-go"
+	safe "github.com/thanhps42/safe-go"
+)
+
+// safeModule is the module path of package safe, as reported in Sentry frames.
+const safeModule = "github.com/thanhps42/safe-go"
+
+// Handler returns a panic handler, suitable for safe.SetPanicHandler, that
+// captures errors as Sentry events on hub. If hub is nil, the current hub is
+// used at the time of each capture.
+//
+// If an error is a safe.PanicError, the event's exception type is the type of
+// the panic value and its stack trace is the one captured when the panic was
+// recovered, so that similar panics are grouped together in Sentry. The
+// exception is marked as unhandled.
+func Handler(hub *sentry.Hub) func(err error) {
+	return func(err error) {
+		h := hub
+		if h == nil {
+			h = sentry.CurrentHub()
+		}
+		h.CaptureEvent(Event(err))
+	}
+}
+
+// Event returns a Sentry event describing err.
+func Event(err error) *sentry.Event {
+	exc := sentry.Exception{
+		Type:       fmt.Sprintf("%T", err),
+		Value:      err.Error(),
+		Stacktrace: sentry.ExtractStacktrace(err),
+	}
+	if p, ok := safe.AsPanicError(err); ok {
+		exc.Type = fmt.Sprintf("%T", p.Panic())
+		exc.Mechanism = &sentry.Mechanism{Type: "panic"}
+		exc.Mechanism.SetUnhandled()
+	}
+	if exc.Stacktrace != nil {
+		// Frames recovering the panic are not part of the application.
+		for i := range exc.Stacktrace.Frames {
+			if exc.Stacktrace.Frames[i].Module == safeModule {
+				exc.Stacktrace.Frames[i].InApp = false
+			}
+		}
+	}
+
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelError
+	event.Message = err.Error()
+	event.Exception = []sentry.Exception{exc}
+	return event
+}
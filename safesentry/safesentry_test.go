@@ -0,0 +1,73 @@
+package safesentry_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	safe "github.com/thanhps42/safe-go"
+	"github.com/thanhps42/safe-go/safesentry"
+)
+
+// transportStub is a sentry.Transport recording the events sent.
+type transportStub struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *transportStub) Flush(time.Duration) bool { return true }
+
+func (t *transportStub) Configure(sentry.ClientOptions) {}
+
+func (t *transportStub) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func TestHandler(t *testing.T) {
+	transport := &transportStub{}
+	client, err := sentry.NewClient(sentry.ClientOptions{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := sentry.NewHub(client, sentry.NewScope())
+
+	safesentry.Handler(hub)(safe.Do(func() error { panic(42) }))
+
+	if len(transport.events) != 1 {
+		t.Fatalf("sent %d events, want 1", len(transport.events))
+	}
+	excs := transport.events[0].Exception
+	if len(excs) != 1 {
+		t.Fatalf("got %d exceptions, want 1", len(excs))
+	}
+	exc := excs[0]
+	if exc.Type != "int" || exc.Value != "panic: 42" {
+		t.Errorf("got exception %s: %s, want int: panic: 42", exc.Type, exc.Value)
+	}
+	if exc.Mechanism == nil || exc.Mechanism.Type != "panic" || exc.Mechanism.Handled == nil || *exc.Mechanism.Handled {
+		t.Errorf("got mechanism %+v, want an unhandled panic", exc.Mechanism)
+	}
+
+	if exc.Stacktrace == nil || len(exc.Stacktrace.Frames) == 0 {
+		t.Fatal("got no stack trace")
+	}
+	// Sentry frames are ordered from the outermost to the innermost, which
+	// is the recover site in package safe.
+	var inApp string
+	for _, f := range exc.Stacktrace.Frames {
+		if f.Module == "github.com/thanhps42/safe-go" {
+			if f.InApp {
+				t.Errorf("frame %s in package safe is marked in-app", f.Function)
+			}
+			continue
+		}
+		inApp = f.Function
+	}
+	if !strings.HasPrefix(inApp, "TestHandler.func") {
+		t.Errorf("got innermost in-app frame %s, want the panic site in TestHandler", inApp)
+	}
+}
@@ -0,0 +1,68 @@
+// Package safetest provides helpers for testing code that panics, using the
+// recovery provided by package safe.
+package safetest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+// RequirePanic calls fn and returns the recovered panic as a safe.PanicError.
+// If fn does not panic, the test fails immediately.
+func RequirePanic(t testing.TB, fn func()) *safe.PanicError {
+	t.Helper()
+	err := safe.Do(func() error {
+		fn()
+		return nil
+	})
+	p, ok := safe.AsPanicError(err)
+	if !ok {
+		t.Fatal("expected function to panic, but it returned normally")
+	}
+	return p
+}
+
+// AssertNoPanic calls fn and marks the test as failed if it panics, reporting
+// the recovered panic along with its stack trace. It reports whether fn
+// returned normally.
+func AssertNoPanic(t testing.TB, fn func()) bool {
+	t.Helper()
+	err := safe.Do(func() error {
+		fn()
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected panic: %+v", err)
+		return false
+	}
+	return true
+}
+
+// leakTimeout is how long AssertNoLeaks waits for tracked goroutines to exit.
+const leakTimeout = time.Second
+
+// AssertNoLeaks marks the test as failed if any goroutines tracked by
+// safe.TrackGoroutines are still running, listing their spawn sites. It waits
+// briefly for goroutines that are about to exit. It reports whether no leaked
+// goroutines were found.
+//
+// Tracking must be enabled with safe.TrackGoroutines(true) before the
+// goroutines are spawned.
+func AssertNoLeaks(t testing.TB) bool {
+	t.Helper()
+	deadline := time.Now().Add(leakTimeout)
+	for {
+		active := safe.ActiveGoroutines()
+		if len(active) == 0 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("%d leaked goroutines spawned at:\n%s", len(active), strings.Join(active, "\n"))
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,150 @@
+package safetest_test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+	"github.com/thanhps42/safe-go/safetest"
+)
+
+// fakeTB is a testing.TB that records failures instead of failing the test.
+type fakeTB struct {
+	testing.TB // unimplemented methods panic
+
+	mu     sync.Mutex
+	failed bool
+	msgs   []string
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Errorf(format string, args ...interface{}) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.failed = true
+	tb.msgs = append(tb.msgs, fmt.Sprintf(format, args...))
+}
+
+// Fatal records a failure and stops the calling goroutine, like testing.T.
+func (tb *fakeTB) Fatal(args ...interface{}) {
+	tb.mu.Lock()
+	tb.failed = true
+	tb.msgs = append(tb.msgs, fmt.Sprint(args...))
+	tb.mu.Unlock()
+	runtime.Goexit()
+}
+
+// output returns the recorded messages.
+func (tb *fakeTB) output() string {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return strings.Join(tb.msgs, "\n")
+}
+
+// run calls fn with a fakeTB in a new goroutine, so that Fatal can stop it, and
+// returns the fakeTB once fn has returned or been stopped.
+func run(fn func(tb *fakeTB)) *fakeTB {
+	tb := &fakeTB{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(tb)
+	}()
+	<-done
+	return tb
+}
+
+func TestRequirePanic(t *testing.T) {
+	tb := run(func(tb *fakeTB) {
+		p := safetest.RequirePanic(tb, func() { panic("boom") })
+		if p == nil || p.Panic() != "boom" {
+			t.Errorf("RequirePanic() = %v, want a panic of boom", p)
+		}
+	})
+	if tb.failed {
+		t.Errorf("RequirePanic failed the test on a panic: %s", tb.output())
+	}
+}
+
+func TestRequirePanicFails(t *testing.T) {
+	returned := false
+	tb := run(func(tb *fakeTB) {
+		safetest.RequirePanic(tb, func() {})
+		returned = true
+	})
+	if !tb.failed {
+		t.Error("RequirePanic did not fail the test without a panic")
+	}
+	if returned {
+		t.Error("RequirePanic returned without a panic, want the test stopped")
+	}
+}
+
+func TestAssertNoPanic(t *testing.T) {
+	tb := run(func(tb *fakeTB) {
+		if !safetest.AssertNoPanic(tb, func() {}) {
+			t.Error("AssertNoPanic() = false without a panic")
+		}
+	})
+	if tb.failed {
+		t.Errorf("AssertNoPanic failed the test without a panic: %s", tb.output())
+	}
+}
+
+func TestAssertNoPanicFails(t *testing.T) {
+	tb := run(func(tb *fakeTB) {
+		if safetest.AssertNoPanic(tb, func() { panic("boom") }) {
+			t.Error("AssertNoPanic() = true on a panic")
+		}
+	})
+	if !tb.failed {
+		t.Fatal("AssertNoPanic did not fail the test on a panic")
+	}
+	// The failure reports the panic value and where it occurred.
+	out := tb.output()
+	if !strings.Contains(out, "boom") || !strings.Contains(out, "TestAssertNoPanicFails") {
+		t.Errorf("got failure %q, want the panic value and stack", out)
+	}
+}
+
+func TestAssertNoLeaks(t *testing.T) {
+	safe.TrackGoroutines(true)
+	t.Cleanup(func() { safe.TrackGoroutines(false) })
+
+	done := make(chan struct{})
+	safe.Go(func() { close(done) })
+	<-done
+	tb := run(func(tb *fakeTB) {
+		if !safetest.AssertNoLeaks(tb) {
+			t.Error("AssertNoLeaks() = false after the goroutine returned")
+		}
+	})
+	if tb.failed {
+		t.Errorf("AssertNoLeaks failed the test without leaks: %s", tb.output())
+	}
+}
+
+func TestAssertNoLeaksFails(t *testing.T) {
+	safe.TrackGoroutines(true)
+	t.Cleanup(func() { safe.TrackGoroutines(false) })
+
+	release := make(chan struct{})
+	defer close(release)
+	safe.Go(func() { <-release })
+	tb := run(func(tb *fakeTB) {
+		if safetest.AssertNoLeaks(tb) {
+			t.Error("AssertNoLeaks() = true with a leaked goroutine")
+		}
+	})
+	if !tb.failed {
+		t.Fatal("AssertNoLeaks did not fail the test with a leaked goroutine")
+	}
+	// The failure lists where the leaked goroutine was spawned.
+	if out := tb.output(); !strings.Contains(out, "safetest_test.go:") {
+		t.Errorf("got failure %q, want the spawn site", out)
+	}
+}
@@ -0,0 +1,17 @@
+package safe
+
+import "context"
+
+// Shutdown drains the package before the program exits. It waits for the
+// goroutines tracked with TrackGoroutines to return, as with WaitAll, and then
+// for the panics queued for the asynchronous panic handler to be handled, as
+// with FlushPanics. If ctx is done first, it returns ctx.Err().
+//
+// It returns immediately if neither tracking nor an asynchronous panic handler
+// is enabled.
+func Shutdown(ctx context.Context) error {
+	if err := WaitAll(ctx); err != nil {
+		return err
+	}
+	return FlushPanics(ctx)
+}
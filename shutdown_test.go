@@ -0,0 +1,47 @@
+package safe_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestShutdown(t *testing.T) {
+	safe.TrackGoroutines(true)
+	t.Cleanup(func() { safe.TrackGoroutines(false) })
+	var handled atomic.Int32
+	safe.SetAsyncPanicHandler(func(error) {
+		time.Sleep(10 * time.Millisecond)
+		handled.Add(1)
+	}, 10)
+	t.Cleanup(safe.ResetPanicHandler)
+
+	for i := 0; i < 3; i++ {
+		safe.Go(func() { panic("boom") })
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := safe.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if n := handled.Load(); n != 3 {
+		t.Errorf("%d panics handled by Shutdown, want 3", n)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	safe.TrackGoroutines(true)
+	t.Cleanup(func() { safe.TrackGoroutines(false) })
+	release := make(chan struct{})
+	defer close(release)
+	safe.Go(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := safe.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
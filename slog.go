@@ -0,0 +1,35 @@
+package safe
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SetSlogHandler configures a global panic handler that logs panics to logger
+// at the given level. Each record has the following attributes:
+//
+//   - error: the error message
+//   - panic_type: the type of the panic value
+//   - panic_value: the string form of the panic value
+//   - stack: the stack trace of the panic
+//
+// Like SetPanicHandler, it replaces any previously configured handlers.
+func SetSlogHandler(logger *slog.Logger, level slog.Level) {
+	SetPanicHandler(func(err error) {
+		logger.LogAttrs(context.Background(), level, "recovered panic", slogAttrs(err)...)
+	})
+}
+
+// slogAttrs returns the attributes describing err.
+func slogAttrs(err error) []slog.Attr {
+	attrs := []slog.Attr{slog.String("error", err.Error())}
+	if p, ok := AsPanicError(err); ok {
+		attrs = append(attrs,
+			slog.String("panic_type", fmt.Sprintf("%T", p.Panic())),
+			slog.String("panic_value", fmt.Sprint(p.Panic())),
+			slog.String("stack", p.StackString()),
+		)
+	}
+	return attrs
+}
@@ -0,0 +1,24 @@
+package safe
+
+import "sort"
+
+// SafeSlice sorts s according to less, like sort.Slice, but recovers a panic in
+// less and returns it as a safe.PanicError, aborting the sort.
+//
+// The elements are sorted in a copy of s that is only copied back once the sort
+// completes, so if less panics, s is left in its original order rather than
+// partially sorted. This requires a temporary copy of s.
+func SafeSlice[T any](s []T, less func(a, b T) bool) error {
+	sorted := append([]T(nil), s...)
+	err := Do(func() error {
+		sort.Slice(sorted, func(i, j int) bool {
+			return less(sorted[i], sorted[j])
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	copy(s, sorted)
+	return nil
+}
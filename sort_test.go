@@ -0,0 +1,35 @@
+package safe_test
+
+import (
+	"fmt"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestSafeSlice(t *testing.T) {
+	s := []int{5, 2, 4, 1, 3}
+	if err := safe.SafeSlice(s, func(a, b int) bool { return a < b }); err != nil {
+		t.Fatalf("SafeSlice() = %v", err)
+	}
+	if got := fmt.Sprint(s); got != "[1 2 3 4 5]" {
+		t.Errorf("got %s, want [1 2 3 4 5]", got)
+	}
+}
+
+func TestSafeSlicePanic(t *testing.T) {
+	s := []int{5, 2, 4, 1, 3, 9, 8, 7, 6, 0, 11, 10, 12, 14, 13}
+	want := fmt.Sprint(s)
+	calls := 0
+	err := safe.SafeSlice(s, func(a, b int) bool {
+		// Panic part way through, after elements have been moved.
+		if calls++; calls == 20 {
+			panic("boom")
+		}
+		return a < b
+	})
+	requirePanicError(t, err, "boom")
+	if got := fmt.Sprint(s); got != want {
+		t.Errorf("got %s after a panic, want the original order %s", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package safe
+
+import "sync"
+
+// A Result holds the outcome of a function passed to StreamGroup.Go: either
+// its value or its error, which is a safe.PanicError if the function panicked.
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// A StreamGroup is a collection of goroutines whose results are streamed to a
+// consumer as they complete, rather than collected until all have finished.
+// Results are delivered in completion order. If any panics occur, they will be
+// recovered and delivered as results with a safe.PanicError.
+//
+// Unlike Group, an error from one function does not affect the others.
+type StreamGroup[T any] struct {
+	results   chan Result[T]
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewStreamGroup returns a new StreamGroup whose results channel buffers up to
+// buffer results. Once the buffer is full, completed functions block until the
+// consumer receives their results.
+func NewStreamGroup[T any](buffer int) *StreamGroup[T] {
+	return &StreamGroup[T]{results: make(chan Result[T], buffer)}
+}
+
+// Go calls the given function in a new goroutine and delivers its result on the
+// results channel.
+//
+// Go must not be called after Close.
+func (g *StreamGroup[T]) Go(fn func() (T, error)) {
+	pc := caller()
+	g.wg.Add(1)
+	spawn(pc, func() {
+		defer g.wg.Done()
+		v, err := DoTyped(fn)
+		g.results <- Result[T]{Value: v, Err: err}
+	})
+}
+
+// Close indicates that no more functions will be passed to Go. The results
+// channel is closed once all functions have returned and their results have
+// been delivered. Close does not block.
+func (g *StreamGroup[T]) Close() {
+	g.closeOnce.Do(func() {
+		go func() {
+			g.wg.Wait()
+			close(g.results)
+		}()
+	})
+}
+
+// Results returns the channel on which results are delivered. It is closed
+// once Close has been called and all results have been delivered.
+func (g *StreamGroup[T]) Results() <-chan Result[T] {
+	return g.results
+}
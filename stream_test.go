@@ -0,0 +1,34 @@
+package safe_test
+
+import (
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestStreamGroup(t *testing.T) {
+	g := safe.NewStreamGroup[int](0)
+	for i := 1; i <= 3; i++ {
+		i := i
+		g.Go(func() (int, error) {
+			if i == 2 {
+				panic("boom")
+			}
+			return i, nil
+		})
+	}
+	g.Close()
+
+	sum, panics := 0, 0
+	for r := range g.Results() {
+		if r.Err != nil {
+			requirePanicError(t, r.Err, "boom")
+			panics++
+			continue
+		}
+		sum += r.Value
+	}
+	if sum != 4 || panics != 1 {
+		t.Errorf("got values summing to %d and %d panics, want 4 and 1", sum, panics)
+	}
+}
@@ -0,0 +1,62 @@
+package safe
+
+import (
+	"context"
+	"time"
+)
+
+// SuperviseOptions configures how Supervise restarts a function.
+type SuperviseOptions struct {
+	// MaxRestarts is the maximum number of times fn is restarted after a panic.
+	// Zero or a negative value means there is no limit.
+	MaxRestarts int
+
+	// Backoff returns how long to wait before the given restart attempt,
+	// starting at 1. If nil, fn is restarted immediately.
+	Backoff func(attempt int) time.Duration
+}
+
+// Supervise executes fn, restarting it each time it panics until it returns
+// normally or ctx is canceled. Panics are passed to the global panic handler.
+//
+// Supervise blocks until fn returns normally, in which case it returns nil, or
+// until ctx is canceled, in which case it returns ctx.Err().
+func Supervise(ctx context.Context, fn func(ctx context.Context)) error {
+	return SuperviseWithOptions(ctx, SuperviseOptions{}, fn)
+}
+
+// SuperviseWithOptions is like Supervise, but restarts fn according to opts.
+// If fn panics after the maximum number of restarts, the last panic is
+// returned as a safe.PanicError.
+func SuperviseWithOptions(ctx context.Context, opts SuperviseOptions, fn func(ctx context.Context)) error {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := superviseOnce(ctx, fn)
+		if err == nil {
+			return nil
+		}
+		if opts.MaxRestarts > 0 && attempt > opts.MaxRestarts {
+			return err
+		}
+		if opts.Backoff == nil {
+			continue
+		}
+		if err := sleep(ctx, opts.Backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// superviseOnce executes fn, passing any panic to the global panic handler and
+// returning it as a safe.PanicError.
+func superviseOnce(ctx context.Context, fn func(ctx context.Context)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = handlePanic(r, 0)
+		}
+	}()
+	fn(ctx)
+	return nil
+}
@@ -0,0 +1,41 @@
+package safe_test
+
+import (
+	"context"
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestSupervise(t *testing.T) {
+	errs := handlePanics(t)
+	calls := 0
+	err := safe.Supervise(context.Background(), func(context.Context) {
+		calls++
+		if calls <= 2 {
+			panic(calls)
+		}
+	})
+	if err != nil {
+		t.Errorf("Supervise() = %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("fn called %d times, want 3: two panics and one return", calls)
+	}
+	for i := 1; i <= 2; i++ {
+		requirePanicError(t, <-errs, i)
+	}
+}
+
+func TestSuperviseMaxRestarts(t *testing.T) {
+	handlePanics(t)
+	calls := 0
+	err := safe.SuperviseWithOptions(context.Background(), safe.SuperviseOptions{MaxRestarts: 2}, func(context.Context) {
+		calls++
+		panic(calls)
+	})
+	if calls != 3 {
+		t.Errorf("fn called %d times, want 3", calls)
+	}
+	requirePanicError(t, err, 3)
+}
@@ -0,0 +1,67 @@
+package safe
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// GoWithTimeout executes fn in a background goroutine. If a panic occurs, it
+// will be recovered and passed to the global panic handler.
+//
+// The returned channel is closed when fn returns or after d has elapsed,
+// whichever happens first, so waiting on it never blocks for longer than d. fn
+// is not stopped when d elapses and continues running in the background.
+func GoWithTimeout(d time.Duration, fn func()) <-chan struct{} {
+	done := make(chan struct{})
+	var once sync.Once
+	closeDone := func() {
+		once.Do(func() { close(done) })
+	}
+
+	t := time.AfterFunc(d, closeDone)
+	goFrom(caller(), func() {
+		defer t.Stop()
+		defer closeDone()
+		fn()
+	})
+	return done
+}
+
+const (
+	timeoutRunning int32 = iota
+	timeoutFinished
+	timeoutAbandoned
+)
+
+// DoWithTimeout executes fn in a background goroutine and waits up to d for it
+// to return. If a panic occurs, it will be recovered and returned as a
+// safe.PanicError. If fn does not return in time, context.DeadlineExceeded is
+// returned.
+//
+// fn is not stopped when d elapses and continues running in the background. If
+// it later panics, the panic is passed to the global panic handler instead.
+func DoWithTimeout(d time.Duration, fn func() error) error {
+	pc := caller()
+	var state int32
+	done := make(chan error, 1)
+	spawn(pc, func() {
+		err := Do(fn)
+		if atomic.CompareAndSwapInt32(&state, timeoutRunning, timeoutFinished) {
+			done <- err
+		} else if IsPanic(err) {
+			reportPanic(err)
+		}
+	})
+
+	select {
+	case err := <-done:
+		return err
+	case <-after(d):
+		if atomic.CompareAndSwapInt32(&state, timeoutRunning, timeoutAbandoned) {
+			return context.DeadlineExceeded
+		}
+		return <-done
+	}
+}
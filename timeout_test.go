@@ -0,0 +1,48 @@
+package safe_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestGoWithTimeout(t *testing.T) {
+	errs := handlePanics(t)
+	select {
+	case <-safe.GoWithTimeout(time.Minute, func() { panic("boom") }):
+	case <-time.After(5 * time.Second):
+		t.Fatal("done channel not closed when fn returned")
+	}
+	requirePanicError(t, <-errs, "boom")
+
+	release := make(chan struct{})
+	defer close(release)
+	select {
+	case <-safe.GoWithTimeout(10*time.Millisecond, func() { <-release }):
+	case <-time.After(5 * time.Second):
+		t.Fatal("done channel not closed after the timeout")
+	}
+}
+
+func TestDoWithTimeout(t *testing.T) {
+	if err := safe.DoWithTimeout(time.Minute, func() error { return errPartial }); err != errPartial {
+		t.Errorf("DoWithTimeout() = %v, want %v", err, errPartial)
+	}
+	err := safe.DoWithTimeout(time.Minute, func() error { panic("boom") })
+	requirePanicError(t, err, "boom")
+
+	// A panic after the timeout goes to the global panic handler instead.
+	errs := handlePanics(t)
+	release := make(chan struct{})
+	err = safe.DoWithTimeout(10*time.Millisecond, func() error {
+		<-release
+		panic("late")
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("DoWithTimeout() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+	requirePanicError(t, <-errs, "late")
+}
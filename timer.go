@@ -0,0 +1,145 @@
+package safe
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AfterFunc is like time.AfterFunc, but if a panic occurs in fn, it will be
+// recovered and passed to the global panic handler instead of crashing the
+// program.
+func AfterFunc(d time.Duration, fn func()) *time.Timer {
+	pc := caller()
+	return time.AfterFunc(d, func() {
+		runFrom(pc, fn)
+	})
+}
+
+// Tick calls fn every d until ctx is canceled. If a panic occurs in fn, it will
+// be recovered and passed to the global panic handler, and fn continues to be
+// called on subsequent ticks. Ticks are dropped while fn is running, as with
+// time.Ticker.
+//
+// Tick blocks until ctx is canceled.
+func Tick(ctx context.Context, d time.Duration, fn func()) {
+	pc := caller()
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			runFrom(pc, fn)
+		}
+	}
+}
+
+// Periodic calls fn every interval until ctx is canceled, passing it ctx. Each
+// call runs in its own goroutine, and ticks that occur while the previous call
+// is still running are skipped, so calls never overlap. If fn returns a non-nil
+// error or panics, the error or recovered panic is passed to the global panic
+// handler.
+//
+// Periodic blocks until ctx is canceled and any running call has returned.
+func Periodic(ctx context.Context, interval time.Duration, fn func(ctx context.Context) error) {
+	pc := caller()
+	var (
+		running atomic.Bool
+		wg      sync.WaitGroup
+	)
+	defer wg.Wait()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if !running.CompareAndSwap(false, true) {
+				continue
+			}
+			wg.Add(1)
+			spawn(pc, func() {
+				defer wg.Done()
+				defer running.Store(false)
+				runFrom(pc, func() {
+					if err := fn(ctx); err != nil {
+						reportPanic(err)
+					}
+				})
+			})
+		}
+	}
+}
+
+// Debounce returns a trigger function that schedules fn to be called once d has
+// elapsed without further triggers, so that rapid triggers are collapsed into a
+// single call. If a panic occurs in fn, it will be recovered and passed to the
+// global panic handler.
+//
+// The returned cancel function stops any pending call. Triggering again after
+// cancel schedules a new call.
+func Debounce(d time.Duration, fn func()) (trigger func(), cancel func()) {
+	pc := caller()
+	var (
+		mu  sync.Mutex
+		t   *time.Timer
+		gen uint64 // incremented by each trigger and cancel
+	)
+	stop := func() {
+		gen++
+		if t != nil {
+			t.Stop()
+			t = nil
+		}
+	}
+	trigger = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stop()
+		g := gen
+		t = time.AfterFunc(d, func() {
+			mu.Lock()
+			// Skip calls superseded by a trigger or cancel after the timer
+			// fired.
+			current := g == gen
+			mu.Unlock()
+			if current {
+				runFrom(pc, fn)
+			}
+		})
+	}
+	cancel = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stop()
+	}
+	return trigger, cancel
+}
+
+// Throttle returns a trigger function that calls fn in a background goroutine
+// at most once every d. The first trigger calls fn immediately, and triggers
+// within d of the last call are dropped. If a panic occurs in fn, it will be
+// recovered and passed to the global panic handler.
+func Throttle(d time.Duration, fn func()) func() {
+	pc := caller()
+	var (
+		mu   sync.Mutex
+		last time.Time // time of the last call, or zero
+	)
+	return func() {
+		mu.Lock()
+		now := getClock().Now()
+		if !last.IsZero() && now.Sub(last) < d {
+			mu.Unlock()
+			return
+		}
+		last = now
+		mu.Unlock()
+		goFrom(pc, fn)
+	}
+}
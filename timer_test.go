@@ -0,0 +1,107 @@
+package safe_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestTick(t *testing.T) {
+	errs := handlePanics(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	safe.Tick(ctx, time.Millisecond, func() {
+		calls++
+		if calls == 3 {
+			cancel()
+		}
+		panic(calls)
+	})
+	// A tick may still be taken once ctx is canceled.
+	if calls < 3 {
+		t.Errorf("fn called %d times, want at least 3", calls)
+	}
+	for i := 1; i <= calls; i++ {
+		requirePanicError(t, <-errs, i)
+	}
+}
+
+func TestPeriodic(t *testing.T) {
+	errs := handlePanics(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		calls, active atomic.Int32
+		overlap       atomic.Bool
+	)
+	safe.Periodic(ctx, time.Millisecond, func(context.Context) error {
+		defer active.Add(-1)
+		if active.Add(1) > 1 {
+			overlap.Store(true)
+		}
+		switch calls.Add(1) {
+		case 1:
+			// Let several ticks pass, which must be skipped.
+			time.Sleep(20 * time.Millisecond)
+		case 2:
+			panic("boom")
+		case 3:
+			cancel()
+			return errPartial
+		}
+		return nil
+	})
+	if overlap.Load() {
+		t.Error("calls overlapped")
+	}
+	if n := calls.Load(); n < 3 {
+		t.Errorf("fn called %d times, want at least 3", n)
+	}
+	requirePanicError(t, <-errs, "boom")
+	if err := <-errs; err != errPartial {
+		t.Errorf("panic handler got %v, want %v", err, errPartial)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	errs := handlePanics(t)
+	var calls atomic.Int32
+	trigger, cancel := safe.Debounce(20*time.Millisecond, func() {
+		calls.Add(1)
+		panic("boom")
+	})
+	for i := 0; i < 5; i++ {
+		trigger()
+	}
+	requirePanicError(t, <-errs, "boom")
+
+	trigger()
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn called %d times, want 1", n)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	errs := handlePanics(t)
+	clock := useFakeClock(t)
+	var calls atomic.Int32
+	trigger := safe.Throttle(time.Second, func() {
+		panic(calls.Add(1))
+	})
+
+	trigger()
+	clock.Advance(999 * time.Millisecond)
+	trigger() // dropped
+	requirePanicError(t, <-errs, int32(1))
+
+	clock.Advance(time.Millisecond)
+	trigger()
+	requirePanicError(t, <-errs, int32(2))
+	if n := calls.Load(); n != 2 {
+		t.Errorf("fn called %d times, want 2", n)
+	}
+}
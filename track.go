@@ -0,0 +1,134 @@
+package safe
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	tracking atomic.Bool // whether goroutines are tracked
+	tracked  struct {
+		mu   sync.Mutex
+		next uint64
+		pcs  map[uint64]uintptr // spawn sites of running goroutines by ID
+		idle chan struct{}      // closed when no goroutines are running
+
+		goids map[uint64]int // goroutine IDs running recovered functions, with nesting depth
+	}
+)
+
+// TrackGoroutines enables or disables tracking of the goroutines spawned by
+// safe.Go and similar functions, so that those still running can be listed with
+// ActiveGoroutines or waited for with WaitAll. It is intended for detecting
+// leaked goroutines in tests and draining background work during shutdown.
+// Tracking is disabled by default and has no overhead when disabled.
+//
+// Only goroutines spawned while tracking is enabled are tracked. Tracking also
+// enables InRecoveredContext, which always returns false without it.
+func TrackGoroutines(enable bool) {
+	tracking.Store(enable)
+}
+
+// ActiveGoroutines returns the "file:line" spawn sites of the tracked
+// goroutines that are still running.
+func ActiveGoroutines() []string {
+	tracked.mu.Lock()
+	defer tracked.mu.Unlock()
+	sites := make([]string, 0, len(tracked.pcs))
+	for _, pc := range tracked.pcs {
+		sites = append(sites, location(pc))
+	}
+	return sites
+}
+
+// WaitAll blocks until all tracked goroutines have returned, including those
+// that panicked, or until ctx is done, in which case it returns ctx.Err().
+// Goroutines are only tracked while enabled with TrackGoroutines.
+func WaitAll(ctx context.Context) error {
+	tracked.mu.Lock()
+	if len(tracked.pcs) == 0 {
+		tracked.mu.Unlock()
+		return nil
+	}
+	if tracked.idle == nil {
+		tracked.idle = make(chan struct{})
+	}
+	idle := tracked.idle
+	tracked.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track records a goroutine spawned by the call at pc, returning an ID to
+// pass to untrack once it exits.
+func track(pc uintptr) uint64 {
+	tracked.mu.Lock()
+	defer tracked.mu.Unlock()
+	if tracked.pcs == nil {
+		tracked.pcs = make(map[uint64]uintptr)
+	}
+	tracked.next++
+	tracked.pcs[tracked.next] = pc
+	return tracked.next
+}
+
+// untrack removes a goroutine recorded by track.
+func untrack(id uint64) {
+	tracked.mu.Lock()
+	defer tracked.mu.Unlock()
+	delete(tracked.pcs, id)
+	if len(tracked.pcs) == 0 && tracked.idle != nil {
+		close(tracked.idle)
+		tracked.idle = nil
+	}
+}
+
+// InRecoveredContext reports whether the calling goroutine is running a
+// function whose panics are recovered by this package, so that libraries can
+// decide whether to install their own recovery.
+//
+// Go has no goroutine-local state, so this is best effort, with two
+// limitations:
+//   - It always returns false unless tracking is enabled with TrackGoroutines,
+//     and only detects goroutines spawned while it is enabled.
+//   - It only detects goroutines spawned by safe.Go and similar functions, and
+//     functions passed to Group.Go. Functions called synchronously by Do,
+//     DoTyped, DoWithResult and the like are never detected, as marking them
+//     would add overhead to every call.
+//
+// A false result therefore does not mean that panics are unrecovered. Functions
+// passed a context by GoCtx, GoFrom or DoWithContext can use IsRecoveredContext
+// instead, which has neither limitation.
+func InRecoveredContext() bool {
+	tracked.mu.Lock()
+	defer tracked.mu.Unlock()
+	return len(tracked.goids) > 0 && tracked.goids[goroutineID()] > 0
+}
+
+// enterRecovered marks the calling goroutine as running a recovered function,
+// returning its ID to pass to leaveRecovered once the function returns.
+func enterRecovered() uint64 {
+	id := goroutineID()
+	tracked.mu.Lock()
+	defer tracked.mu.Unlock()
+	if tracked.goids == nil {
+		tracked.goids = make(map[uint64]int)
+	}
+	tracked.goids[id]++
+	return id
+}
+
+// leaveRecovered removes a mark added by enterRecovered.
+func leaveRecovered(id uint64) {
+	tracked.mu.Lock()
+	defer tracked.mu.Unlock()
+	if tracked.goids[id]--; tracked.goids[id] <= 0 {
+		delete(tracked.goids, id)
+	}
+}
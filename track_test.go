@@ -0,0 +1,60 @@
+package safe_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+func TestActiveGoroutines(t *testing.T) {
+	safe.TrackGoroutines(true)
+	t.Cleanup(func() { safe.TrackGoroutines(false) })
+
+	release := make(chan struct{})
+	safe.Go(func() { <-release })
+	p := safe.NewPool(1)
+	s := safe.NewStreamGroup[int](1)
+	s.Go(func() (int, error) {
+		<-release
+		return 0, nil
+	})
+	go func() {
+		_ = safe.DoWithTimeout(time.Hour, func() error {
+			<-release
+			return nil
+		})
+	}()
+
+	// Wait for the goroutine started by DoWithTimeout.
+	for i := 0; i < 100 && len(safe.ActiveGoroutines()) < 4; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	active := safe.ActiveGoroutines()
+	if len(active) != 4 {
+		t.Fatalf("ActiveGoroutines() = %q, want 4 goroutines", active)
+	}
+	for _, site := range active {
+		if !strings.Contains(site, "track_test.go:") {
+			t.Errorf("got spawn site %q, want one in track_test.go", site)
+		}
+	}
+
+	close(release)
+	if err := p.Wait(); err != nil {
+		t.Errorf("Pool.Wait() = %v", err)
+	}
+	s.Close()
+	for range s.Results() {
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := safe.WaitAll(ctx); err != nil {
+		t.Fatalf("WaitAll() = %v", err)
+	}
+	if active := safe.ActiveGoroutines(); len(active) != 0 {
+		t.Errorf("ActiveGoroutines() = %q after all returned, want none", active)
+	}
+}
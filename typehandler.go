@@ -0,0 +1,40 @@
+package safe
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+var typeHandlers atomic.Value // panic handlers keyed by reflect.Type of the panic value
+
+// RegisterPanicHandler registers fn as the handler for panics whose value has
+// the same dynamic type as sample, such as a custom error type. Panics passed
+// to the global panic handler are dispatched to the handler registered for the
+// type of their value, if any, instead of the global panic handlers. fn is
+// passed both the panic value and the resulting error. If fn is nil, the
+// handler for the type is removed.
+func RegisterPanicHandler(sample interface{}, fn func(val interface{}, err error)) {
+	panicHandlerMu.Lock()
+	defer panicHandlerMu.Unlock()
+	old, _ := typeHandlers.Load().(map[reflect.Type]func(val interface{}, err error))
+	hs := make(map[reflect.Type]func(val interface{}, err error), len(old)+1)
+	for t, h := range old {
+		hs[t] = h
+	}
+	if fn == nil {
+		delete(hs, reflect.TypeOf(sample))
+	} else {
+		hs[reflect.TypeOf(sample)] = fn
+	}
+	typeHandlers.Store(hs)
+}
+
+// typePanicHandler returns the handler registered for the type of val, or nil
+// if none.
+func typePanicHandler(val interface{}) func(val interface{}, err error) {
+	hs, _ := typeHandlers.Load().(map[reflect.Type]func(val interface{}, err error))
+	if len(hs) == 0 {
+		return nil
+	}
+	return hs[reflect.TypeOf(val)]
+}
@@ -0,0 +1,37 @@
+package safe_test
+
+import (
+	"testing"
+
+	safe "github.com/thanhps42/safe-go"
+)
+
+type customPanic struct{ code int }
+
+func TestRegisterPanicHandler(t *testing.T) {
+	global := handlePanics(t)
+	typed := make(chan error, 1)
+	safe.RegisterPanicHandler(customPanic{}, func(val interface{}, err error) {
+		if val != (customPanic{7}) {
+			t.Errorf("type handler got value %v, want {7}", val)
+		}
+		typed <- err
+	})
+	t.Cleanup(func() { safe.RegisterPanicHandler(customPanic{}, nil) })
+
+	safe.Go(func() { panic(customPanic{7}) })
+	requirePanicError(t, <-typed, customPanic{7})
+
+	// Other types, including pointers to the registered one, go to the global
+	// handler.
+	p := &customPanic{8}
+	safe.Go(func() { panic(p) })
+	requirePanicError(t, <-global, p)
+	safe.Go(func() { panic("boom") })
+	requirePanicError(t, <-global, "boom")
+	select {
+	case err := <-typed:
+		t.Errorf("type handler also got %v", err)
+	default:
+	}
+}